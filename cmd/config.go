@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+)
+
+// configCmd groups the commands for inspecting and creating configuration
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and create pickemcli configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration",
+	Long: `Print the configuration pickemcli resolved from flags, environment
+			variables, the config file and defaults. Passwords are redacted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if file := viper.ConfigFileUsed(); file != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "# Config file: %s\n", file)
+		} else {
+			fmt.Fprintln(cmd.OutOrStdout(), "# No config file found")
+		}
+
+		encoder := yaml.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent(2)
+		if err := encoder.Encode(config.Effective()); err != nil {
+			return err
+		}
+		return encoder.Close()
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for mistakes",
+	Long: `Report unknown keys, values of the wrong type, a malformed
+			app.season.current and non-positive intervals`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems := config.Validate()
+		for _, problem := range problems {
+			fmt.Fprintf(cmd.OutOrStdout(), "✗ %s\n", problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("configuration has %d problem(s)", len(problems))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "✓ Configuration is valid")
+		return nil
+	},
+}
+
+var configInitForce bool
+
+var configInitCmd = &cobra.Command{
+	Use:   "init [path]",
+	Short: "Write a commented starter config file",
+	Long: `Write a starter config file with every setting at its default
+			value (default path: config.yaml)`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "config.yaml"
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+		if configInitForce {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		}
+		file, err := os.OpenFile(path, flags, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		}
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if _, err := file.WriteString(config.StarterConfig()); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "Overwrite an existing file")
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configInitCmd)
+}
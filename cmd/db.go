@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// dbCmd groups the commands that inspect the database
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect the family-pickem.com database",
+}
+
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the Django schema is compatible with pickemcli",
+	Long: `Compare every table and column pickemcli reads or writes against
+			information_schema, check the unique keys its upserts rely on exist,
+			and print a compatibility report`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.Connect(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		report, err := dbUtil.CheckSchema(cmd.Context(), database)
+		if err != nil {
+			return err
+		}
+		report.Write(cmd.OutOrStdout())
+
+		return report.Err()
+	},
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Create the tables pickemcli owns",
+	Long: `Create the pickemcli_ tables pickemcli writes its own results to.
+			They are also created automatically the first time they are needed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.Connect(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		if err := dbUtil.Migrate(cmd.Context(), database); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "✓ pickemcli tables are up to date")
+		return nil
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(dbCheckCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// historyCmd shows the audit trail of changes to one user's statistics
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show how a user's statistics changed over time",
+	Long: `Show the changes pickemcli has written to a user's pickem_api_userstats row,
+			newest first, with the old and new value of each field and the run
+			that wrote it`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uid, _ := cmd.Flags().GetString("uid")
+		fields, _ := cmd.Flags().GetStringSlice("field")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		database, err := db.Connect(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		entries, err := dbUtil.QueryHistory(cmd.Context(), database, dbUtil.HistoryFilter{UserID: uid, Fields: fields, Limit: limit})
+		if err != nil {
+			return err
+		}
+		writeHistory(cmd.OutOrStdout(), uid, entries)
+		return nil
+	},
+}
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete history older than audit.retention_days",
+	Long: `Delete the pickem_api_userstats change history older than
+			audit.retention_days. The daemon does this after every cycle.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days := viper.GetInt("audit.retention_days")
+		if days <= 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "audit.retention_days is 0, history is kept forever")
+			return nil
+		}
+
+		database, err := db.Connect(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		deleted, err := dbUtil.PruneHistory(cmd.Context(), database, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "✓ Deleted %d history entries older than %d days\n", deleted, days)
+		return nil
+	},
+}
+
+// writeHistory writes entries as one line per changed field
+func writeHistory(w io.Writer, uid string, entries []dbUtil.AuditEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "No recorded changes for user %s\n", uid)
+		return
+	}
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s  %-24s %s → %s", e.ChangedAt.Local().Format("2006-01-02 15:04:05"), e.Field, historyValue(e.Old), historyValue(e.New))
+		if e.RunID != "" {
+			fmt.Fprintf(w, "  (run %s)", e.RunID)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func historyValue(v *string) string {
+	if v == nil {
+		return "null"
+	}
+	return *v
+}
+
+func init() {
+	historyCmd.Flags().String("uid", "", "User ID to show the history of")
+	historyCmd.Flags().StringSlice("field", nil, "Only show these fields, e.g. --field weeksWonSeason (repeatable)")
+	historyCmd.Flags().Int("limit", 100, "Show at most this many changes (0 = all)")
+	if err := historyCmd.MarkFlagRequired("uid"); err != nil {
+		panic(err.Error())
+	}
+
+	historyCmd.AddCommand(historyPruneCmd)
+}
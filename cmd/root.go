@@ -1,29 +1,57 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/jimdaga/pickemcli/internal/cli"
+	"github.com/jimdaga/pickemcli/internal/config"
 	"github.com/jimdaga/pickemcli/pkg/daemon"
+	"github.com/jimdaga/pickemcli/pkg/grade"
+	"github.com/jimdaga/pickemcli/pkg/report"
 	"github.com/jimdaga/pickemcli/pkg/userStats"
 )
 
 var Debug bool
 
+// cfgFile is the --config flag; empty means search the default locations
+var cfgFile string
+
 var rootCmd = &cobra.Command{
 	Use:   "pickemcli",
 	Short: "pickemcli is a cli tool for updating the family-pickem.com website",
 	Long:  "pickemcli is a cli tool for updating analytic data and score data for the family-pickem.com website",
+	// Errors are reported once by Execute
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return config.Load(cfgFile)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel the command context on SIGINT/SIGTERM so in-flight queries are
+	// abandoned and the daemon can shut down cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		// A dry run that found differences is a result, not a failure
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			fmt.Fprintln(os.Stderr, exitErr)
+			os.Exit(exitErr.Code)
+		}
 		fmt.Fprintf(os.Stderr, "Oops. An error while executing pickemcli '%s'\n", err)
 		os.Exit(1)
 	}
@@ -32,14 +60,32 @@ func Execute() {
 func addSubcommandPallets() {
 	// Add the main userStats command that runs all analytics
 	rootCmd.AddCommand(userStats.UserStats)
-	
+
 	// Add individual analytics commands for backwards compatibility
 	rootCmd.AddCommand(userStats.PickStats)
 	rootCmd.AddCommand(userStats.TopPicked)
 	rootCmd.AddCommand(userStats.LeastPicked)
-	
+	rootCmd.AddCommand(userStats.LeaderboardCmd)
+	rootCmd.AddCommand(userStats.StreaksCmd)
+	rootCmd.AddCommand(userStats.TeamAccuracyCmd)
+	rootCmd.AddCommand(userStats.ConsensusCmd)
+	rootCmd.AddCommand(userStats.Compare)
+	rootCmd.AddCommand(userStats.SimilarityCmd)
+	rootCmd.AddCommand(userStats.WeekWinners)
+	rootCmd.AddCommand(userStats.SeasonChampion)
+
+	// Add pick grading
+	rootCmd.AddCommand(grade.GradeCmd)
+	rootCmd.AddCommand(report.ReportCmd)
+
 	// Add daemon command
 	rootCmd.AddCommand(daemon.DaemonCmd)
+
+	// Add configuration and database commands
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(snapshotCmd)
 }
 
 func init() {
@@ -56,21 +102,30 @@ func init() {
 		panic(err.Error())
 	}
 
-	addSubcommandPallets()
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file to use (default: config.yaml in ., $XDG_CONFIG_HOME/pickemcli, $XDG_CONFIG_DIRS/pickemcli or /etc/pickemcli)")
 
-	// Load configuration
-	viper.AddConfigPath(".")
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
+	rootCmd.PersistentFlags().String("database-url", "", "PostgreSQL connection URL, used instead of the database.host/port/user/password/name/sslmode settings")
+	if err := viper.BindPFlag("database.url", rootCmd.PersistentFlags().Lookup("database-url")); err != nil {
+		panic(err.Error())
+	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			panic(fmt.Errorf("fatal error reading config file: %w", err))
-		}
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Compute statistics in a read-only transaction and print what would change in pickem_api_userstats, without writing")
+	if err := viper.BindPFlag("dry_run", rootCmd.PersistentFlags().Lookup("dry-run")); err != nil {
+		panic(err.Error())
 	}
 
-	// Set Defaults
-	viper.SetDefault("daemon.interval", 30)
-	viper.SetDefault("app.season.current", "2425")
+	rootCmd.PersistentFlags().String("diff-format", "text", "Format of the --dry-run diff: text or json")
+	if err := viper.BindPFlag("diff_format", rootCmd.PersistentFlags().Lookup("diff-format")); err != nil {
+		panic(err.Error())
+	}
+
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "Format of command results on stdout: table, json, ndjson or csv")
+	if err := viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output")); err != nil {
+		panic(err.Error())
+	}
 
+	addSubcommandPallets()
+
+	// Set Defaults
+	config.SetDefaults()
 }
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// snapshotCmd groups the commands that save and restore copies of
+// pickem_api_userstats
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and restore copies of the user statistics",
+	Long: `Save copies of pickem_api_userstats in pickemcli_snapshots and restore
+			them, to undo a bad statistics run without a database restore`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Save a copy of pickem_api_userstats",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.Connect(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		snap, err := dbUtil.CreateSnapshot(cmd.Context(), database, dbUtil.SnapshotManual)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "✓ Saved snapshot %d of %d UserStats records\n", snap.ID, snap.Rows)
+		return nil
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved snapshots, newest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, err := db.Connect(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		snaps, err := dbUtil.ListSnapshots(cmd.Context(), database)
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		if len(snaps) == 0 {
+			fmt.Fprintln(w, "No snapshots")
+			return nil
+		}
+		fmt.Fprintf(w, "%-6s %-19s  %-11s %6s  %s\n", "ID", "CREATED", "REASON", "ROWS", "RUN")
+		for _, snap := range snaps {
+			fmt.Fprintf(w, "%-6d %-19s  %-11s %6d  %s\n", snap.ID, snap.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+				snap.Reason, snap.Rows, snap.RunID)
+		}
+		return nil
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Write a snapshot back to pickem_api_userstats",
+	Long: `Write a snapshot back to pickem_api_userstats. Every column pickemcli
+			manages is overwritten with the snapshot's value, rows deleted since are
+			recreated and users added since are left alone. The current rows are
+			saved as a pre-restore snapshot first, so a restore can itself be undone;
+			old pre-restore snapshots are pruned only after the restore succeeds.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid snapshot id %q", args[0])
+		}
+
+		ctx := dbUtil.WithRunID(cmd.Context(), uuid.NewString())
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		changed, err := dbUtil.RestoreWithSnapshot(ctx, database, id, viper.GetInt("snapshot.keep"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "✓ Restored snapshot %d, %d user(s) changed (run %s)\n", id, changed, dbUtil.RunID(ctx))
+		return nil
+	},
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+}
@@ -0,0 +1,25 @@
+package cli
+
+import "fmt"
+
+// ExitDifferences is the exit status of a dry run that found changes
+const ExitDifferences = 2
+
+// ExitError is an error that asks Execute to exit with Code rather than 1
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Exit returns an ExitError with code and a formatted message
+func Exit(code int, format string, args ...interface{}) error {
+	return &ExitError{Code: code, Err: fmt.Errorf(format, args...)}
+}
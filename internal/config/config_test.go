@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// sources sets a value for database.url and database.host in each
+// configuration source; an empty value leaves that source unset
+type sources struct {
+	flag, env, databaseURL, file string
+}
+
+// load resets viper and loads the given sources the way the root command
+// does: defaults, then Load with a config file, then a bound flag
+func load(t *testing.T, src sources) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	SetDefaults()
+
+	t.Setenv(EnvPrefix+"_CONFIG", "")
+	t.Setenv(EnvVar("database.url"), src.env)
+	t.Setenv(EnvVar("database.host"), src.env)
+	t.Setenv("DATABASE_URL", src.databaseURL)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	var yaml string
+	if src.file != "" {
+		yaml = "database:\n  url: " + src.file + "\n  host: " + src.file + "\n"
+	}
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(file); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("database-url", "", "")
+	if err := viper.BindPFlag("database.url", cmd.Flags().Lookup("database-url")); err != nil {
+		t.Fatal(err)
+	}
+	if src.flag != "" {
+		if err := cmd.Flags().Set("database-url", src.flag); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      sources
+		wantURL  string
+		wantHost string
+	}{
+		{
+			name:     "defaults",
+			wantURL:  "",
+			wantHost: "localhost",
+		},
+		{
+			name:     "config file",
+			src:      sources{file: "file"},
+			wantURL:  "file",
+			wantHost: "file",
+		},
+		{
+			name:     "DATABASE_URL over config file",
+			src:      sources{databaseURL: "database_url", file: "file"},
+			wantURL:  "database_url",
+			wantHost: "file",
+		},
+		{
+			name:     "PICKEMCLI_ env over DATABASE_URL",
+			src:      sources{env: "env", databaseURL: "database_url", file: "file"},
+			wantURL:  "env",
+			wantHost: "env",
+		},
+		{
+			name:     "flag over everything",
+			src:      sources{flag: "flag", env: "env", databaseURL: "database_url", file: "file"},
+			wantURL:  "flag",
+			wantHost: "env",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			load(t, tt.src)
+			if got := viper.GetString("database.url"); got != tt.wantURL {
+				t.Errorf("database.url = %q, want %q", got, tt.wantURL)
+			}
+			if got := viper.GetString("database.host"); got != tt.wantHost {
+				t.Errorf("database.host = %q, want %q", got, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing config file succeeded")
+	}
+}
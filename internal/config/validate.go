@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// redacted replaces secret values in `config show` output
+const redacted = "********"
+
+// Effective returns the merged configuration from every source, as nested
+// maps, with secrets redacted
+func Effective() map[string]interface{} {
+	settings := viper.AllSettings()
+	for _, s := range Settings {
+		if !s.Secret {
+			continue
+		}
+		value := viper.GetString(s.Key)
+		if value == "" {
+			continue
+		}
+		setNested(settings, s.Key, redactSecret(value))
+	}
+	return settings
+}
+
+// redactSecret hides a secret value. URLs keep everything but their
+// password, which is replaced with "xxxxx".
+func redactSecret(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return redacted
+	}
+	return u.Redacted()
+}
+
+func setNested(m map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := m[part].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[part] = child
+		}
+		m = child
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// RegisterCheck sets the check Validate runs on key's string value. The
+// package owning a setting registers its check from init, so config does
+// not have to import it.
+func RegisterCheck(key string, check func(string) error) {
+	setCheck(key, func() error { return check(viper.GetString(key)) })
+}
+
+// RegisterIntCheck is RegisterCheck for a setting with an int value
+func RegisterIntCheck(key string, check func(int) error) {
+	setCheck(key, func() error { return check(viper.GetInt(key)) })
+}
+
+// AtLeast returns a RegisterIntCheck check rejecting values below min
+func AtLeast(min int) func(int) error {
+	return func(value int) error {
+		if value < min {
+			return fmt.Errorf("must be at least %d, got %d", min, value)
+		}
+		return nil
+	}
+}
+
+func setCheck(key string, check func() error) {
+	for i := range Settings {
+		if Settings[i].Key == key {
+			Settings[i].check = check
+			return
+		}
+	}
+	panic(fmt.Sprintf("config: no setting %q to check", key))
+}
+
+// Validate checks the loaded configuration and returns a description of
+// every problem found: unknown keys in the config file or environment,
+// values of the wrong type, an unknown diff_format, negative or
+// non-positive durations and anything rejected by a registered check
+func Validate() []string {
+	var problems []string
+
+	// Unknown keys in the config file
+	if file := viper.ConfigFileUsed(); file != "" {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if filepath.Ext(file) == "" {
+			v.SetConfigType("yaml")
+		}
+		if err := v.ReadInConfig(); err != nil {
+			problems = append(problems, fmt.Sprintf("cannot read %s: %v", file, err))
+		} else {
+			for _, key := range v.AllKeys() {
+				if _, ok := Lookup(key); !ok {
+					problems = append(problems, fmt.Sprintf("unknown key %q in %s", key, file))
+				}
+			}
+		}
+	}
+
+	// Unknown PICKEMCLI_ environment variables
+	known := map[string]bool{EnvPrefix + "_CONFIG": true}
+	for _, s := range Settings {
+		known[EnvVar(s.Key)] = true
+	}
+	var unknownEnv []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, EnvPrefix+"_") && !known[name] {
+			unknownEnv = append(unknownEnv, name)
+		}
+	}
+	sort.Strings(unknownEnv)
+	for _, name := range unknownEnv {
+		problems = append(problems, fmt.Sprintf("unknown environment variable %s", name))
+	}
+
+	// Types
+	for _, s := range Settings {
+		value := viper.Get(s.Key)
+		var err error
+		switch s.Default.(type) {
+		case int:
+			_, err = cast.ToIntE(value)
+		case bool:
+			_, err = cast.ToBoolE(value)
+		case string:
+			_, err = cast.ToStringE(value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: expected a value of type %T, got %v", s.Key, s.Default, value))
+		}
+	}
+
+	// Values
+	if format := viper.GetString("diff_format"); format != "text" && format != "json" {
+		problems = append(problems, fmt.Sprintf("diff_format: must be text or json, got %q", format))
+	}
+	if days := viper.GetInt("audit.retention_days"); days < 0 {
+		problems = append(problems, fmt.Sprintf("audit.retention_days: must not be negative, got %d", days))
+	}
+	if keep := viper.GetInt("snapshot.keep"); keep < 1 {
+		problems = append(problems, fmt.Sprintf("snapshot.keep: must be at least 1, got %d", keep))
+	}
+	if keep := viper.GetInt("snapshot.daemon_keep"); keep < 1 {
+		problems = append(problems, fmt.Sprintf("snapshot.daemon_keep: must be at least 1, got %d", keep))
+	}
+	if interval := viper.GetInt("daemon.interval"); interval <= 0 {
+		problems = append(problems, fmt.Sprintf("daemon.interval: must be a positive number of seconds, got %d", interval))
+	}
+	if grace := viper.GetInt("daemon.shutdown_grace"); grace < 0 {
+		problems = append(problems, fmt.Sprintf("daemon.shutdown_grace: must not be negative, got %d", grace))
+	}
+	for _, s := range Settings {
+		if s.check == nil {
+			continue
+		}
+		if err := s.check(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.Key, err))
+		}
+	}
+
+	return problems
+}
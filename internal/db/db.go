@@ -1,64 +1,229 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/spf13/viper"
 )
 
 // DatabaseConfig holds database connection configuration
 type DatabaseConfig struct {
+	// URL is a postgres:// URL (or key=value connection string) that, when
+	// set, is used instead of Host, Port, User, Database and SSLMode
+	URL string
+
 	Host     string
 	Port     int
 	User     string
 	Password string
 	Database string
 	SSLMode  string
+
+	// PasswordFile is where Password was read from, if anywhere
+	PasswordFile string
+
+	// ConnectRetries is how many times Connect retries the first connection
+	// after a connection error before giving up
+	ConnectRetries int
+	// RetryBackoff is the wait before the first retry; it doubles after
+	// every attempt up to RetryMaxBackoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+
+	// Pool settings. pickemcli shares the database with the Django site, so
+	// these cap how much of it we can take.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// StatementTimeout is applied to every query on the server side; zero
+	// disables it
+	StatementTimeout time.Duration
+	// ConnectTimeout bounds each connection attempt; zero waits forever
+	ConnectTimeout time.Duration
+	// ApplicationName identifies our sessions in pg_stat_activity
+	ApplicationName string
 }
 
-// GetDatabaseConfig returns database configuration from viper or defaults
-func GetDatabaseConfig() DatabaseConfig {
-	viper.SetDefault("database.host", "localhost")
-	viper.SetDefault("database.port", 5432)
-	viper.SetDefault("database.user", "postgres")
-	viper.SetDefault("database.password", "")
-	viper.SetDefault("database.name", "pickem")
-	viper.SetDefault("database.sslmode", "disable")
-
-	return DatabaseConfig{
-		Host:     viper.GetString("database.host"),
-		Port:     viper.GetInt("database.port"),
-		User:     viper.GetString("database.user"),
-		Password: viper.GetString("database.password"),
-		Database: viper.GetString("database.name"),
-		SSLMode:  viper.GetString("database.sslmode"),
+// GetDatabaseConfig returns database configuration from viper.
+// When database.password_file is set the password is read from that file
+// (e.g. a Docker secret), overriding database.password.
+func GetDatabaseConfig() (DatabaseConfig, error) {
+
+	config := DatabaseConfig{
+		URL:             viper.GetString("database.url"),
+		Host:            viper.GetString("database.host"),
+		Port:            viper.GetInt("database.port"),
+		User:            viper.GetString("database.user"),
+		Password:        viper.GetString("database.password"),
+		Database:        viper.GetString("database.name"),
+		SSLMode:         viper.GetString("database.sslmode"),
+		ConnectRetries:  viper.GetInt("database.connect_retries"),
+		RetryBackoff:    viper.GetDuration("database.retry_backoff") * time.Second,
+		RetryMaxBackoff: viper.GetDuration("database.retry_max_backoff") * time.Second,
+
+		MaxOpenConns:     viper.GetInt("database.max_open_conns"),
+		MaxIdleConns:     viper.GetInt("database.max_idle_conns"),
+		ConnMaxLifetime:  viper.GetDuration("database.conn_max_lifetime") * time.Second,
+		StatementTimeout: viper.GetDuration("database.statement_timeout") * time.Second,
+		ConnectTimeout:   viper.GetDuration("database.connect_timeout") * time.Second,
+		ApplicationName:  viper.GetString("database.application_name"),
+	}
+
+	if file := viper.GetString("database.password_file"); file != "" {
+		password, err := os.ReadFile(file)
+		if err != nil {
+			return config, fmt.Errorf("failed to read database.password_file: %w", err)
+		}
+		config.Password = strings.TrimRight(string(password), "\r\n")
+		config.PasswordFile = file
 	}
+
+	return config, nil
 }
 
-// Connect establishes a connection to the PostgreSQL database using configuration
-func Connect() *sql.DB {
-	config := GetDatabaseConfig()
+// DSN returns the lib/pq connection string for config
+func (config DatabaseConfig) DSN() (string, error) {
+	var params []string
+	if config.ConnectTimeout > 0 {
+		params = append(params, dsnParam("connect_timeout", strconv.Itoa(int(config.ConnectTimeout.Seconds()))))
+	}
+	if config.ApplicationName != "" {
+		params = append(params, dsnParam("application_name", config.ApplicationName))
+	}
+	if config.StatementTimeout > 0 {
+		// lib/pq sends parameters it does not recognise to the server as
+		// session settings
+		params = append(params, dsnParam("statement_timeout", strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)))
+	}
+
+	// lib/pq lets later parameters override earlier ones, so anything set
+	// in the URL beats the settings above, and a password file beats both
+	if config.URL != "" {
+		url := config.URL
+		if strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://") {
+			var err error
+			if url, err = pq.ParseURL(url); err != nil {
+				return "", fmt.Errorf("invalid database.url: %w", err)
+			}
+		}
+		params = append(params, url)
+		if config.PasswordFile != "" {
+			params = append(params, dsnParam("password", config.Password))
+		}
+		return strings.Join(params, " "), nil
+	}
 
-	var psqlInfo string
+	params = append(params,
+		dsnParam("host", config.Host),
+		dsnParam("port", strconv.Itoa(config.Port)),
+		dsnParam("user", config.User),
+	)
 	if config.Password != "" {
-		psqlInfo = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			config.Host, config.Port, config.User, config.Password, config.Database, config.SSLMode)
-	} else {
-		psqlInfo = fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=%s",
-			config.Host, config.Port, config.User, config.Database, config.SSLMode)
+		params = append(params, dsnParam("password", config.Password))
+	}
+	params = append(params,
+		dsnParam("dbname", config.Database),
+		dsnParam("sslmode", config.SSLMode),
+	)
+	return strings.Join(params, " "), nil
+}
+
+// dsnParam formats one key=value pair of a connection string, quoting the
+// value when it is empty or contains spaces, quotes or backslashes
+func dsnParam(key, value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return key + "=" + value
 	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return key + "='" + escaped + "'"
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
+// Connect establishes a connection to the PostgreSQL database using configuration.
+// If the database cannot be reached it retries with exponential backoff, so
+// that starting before Postgres is ready (or during a restart) is not fatal.
+// Errors other than connection errors are returned straight away.
+func Connect(ctx context.Context) (*sql.DB, error) {
+	config, err := GetDatabaseConfig()
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := config.DSN()
 	if err != nil {
-		panic(fmt.Errorf("failed to open database connection: %w", err))
+		return nil, err
 	}
 
-	err = db.Ping()
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
-		panic(fmt.Errorf("failed to ping database: %w", err))
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	backoff := config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		if !IsConnectionError(err) || attempt > config.ConnectRetries {
+			db.Close()
+			return nil, fmt.Errorf("failed to ping database: %w", err)
+		}
+
+		log.Printf("Database not reachable (attempt %d of %d): %v; retrying in %v",
+			attempt, config.ConnectRetries+1, err, backoff)
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > config.RetryMaxBackoff {
+			backoff = config.RetryMaxBackoff
+		}
+	}
+}
+
+// IsConnectionError reports whether err means the database could not be
+// reached or the connection was lost, as opposed to a problem with a query
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
 	}
 
-	return db
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		}
+		// Class 08 - Connection Exception
+		return pqErr.Code.Class() == "08"
+	}
+	return false
 }
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetDatabaseConfigPasswordFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "password")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		passwordFile string
+		wantPassword string
+		wantErr      bool
+	}{
+		{name: "plain password", wantPassword: "plain"},
+		{name: "password file overrides", passwordFile: file, wantPassword: "from-file"},
+		{name: "missing password file", passwordFile: filepath.Join(dir, "missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set("database.password", "plain")
+			viper.Set("database.password_file", tt.passwordFile)
+
+			config, err := GetDatabaseConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetDatabaseConfig succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetDatabaseConfig: %v", err)
+			}
+			if config.Password != tt.wantPassword {
+				t.Errorf("Password = %q, want %q", config.Password, tt.wantPassword)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AuditEntry is one field of one user's pickem_api_userstats row changed
+// by an upsert, as recorded in pickemcli_userstats_audit. Old is nil when
+// the field was unset (or the row did not exist).
+type AuditEntry struct {
+	ID        int64
+	UserID    string
+	Field     string
+	Old       *string
+	New       *string
+	RunID     string
+	ChangedAt time.Time
+}
+
+type runIDKey struct{}
+
+// WithRunID returns a context whose upserts are audited under runID
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunID returns the run ID set with WithRunID, or ""
+func RunID(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDKey{}).(string)
+	return runID
+}
+
+// Changes returns the fields of stats that upserting update would change,
+// or every field update sets when stats is nil (no row yet). Fields update
+// leaves unset keep their value and are never reported.
+func (stats *UserStats) Changes(update *UserStats) []FieldChange {
+	old := stats
+	if old == nil {
+		old = &UserStats{UserID: update.UserID}
+	}
+	updated := *old
+	updated.Merge(update)
+	return fieldChanges(old, &updated)
+}
+
+// fieldChanges returns the fields that differ between old and updated. A
+// nil old stands for a missing row.
+func fieldChanges(old, updated *UserStats) []FieldChange {
+	if old == nil {
+		old = &UserStats{UserID: updated.UserID}
+	}
+	var changes []FieldChange
+	oldFields := old.StatFields()
+	for i, field := range updated.StatFields() {
+		if field.Value != oldFields[i].Value {
+			changes = append(changes, FieldChange{Field: field.Name, Old: oldFields[i].Value, New: field.Value})
+		}
+	}
+	return changes
+}
+
+// auditEntries returns the audit entries for changes to userID's row
+func auditEntries(userID, runID string, changes []FieldChange) []AuditEntry {
+	entries := make([]AuditEntry, len(changes))
+	for i, change := range changes {
+		entries[i] = AuditEntry{
+			UserID: userID,
+			Field:  change.Field,
+			Old:    auditValue(change.Old),
+			New:    auditValue(change.New),
+			RunID:  runID,
+		}
+	}
+	return entries
+}
+
+func auditValue(v interface{}) *string {
+	if v == nil {
+		return nil
+	}
+	return StringPtr(fmt.Sprint(v))
+}
+
+const insertAuditQuery = `INSERT INTO pickemcli_userstats_audit ("userID", field, old_value, new_value, run_id)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''))`
+
+// lockUserStats returns the current pickem_api_userstats rows for userIDs,
+// keyed by user ID, and locks them until tx ends so that the audited old
+// values are the ones the upsert replaces
+func lockUserStats(ctx context.Context, tx *sql.Tx, userIDs []string) (map[string]*UserStats, error) {
+	quoted := make([]string, len(userStatsColumns))
+	for i, col := range userStatsColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM pickem_api_userstats WHERE "userID" = ANY($1) FOR UPDATE`,
+		strings.Join(quoted, ", ")), pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("error querying user stats: %w", err)
+	}
+	all, err := scanUserStats(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]*UserStats, len(all))
+	for _, stats := range all {
+		current[stats.UserID] = stats
+	}
+	return current, nil
+}
+
+// HistoryFilter narrows the entries returned by QueryHistory
+type HistoryFilter struct {
+	UserID string
+	// Fields limits the entries to these columns; empty means all
+	Fields []string
+	// Limit caps the number of entries returned; 0 means no limit
+	Limit int
+}
+
+// QueryHistory returns the audit entries matching filter, newest first
+func QueryHistory(ctx context.Context, db *sql.DB, filter HistoryFilter) ([]AuditEntry, error) {
+	query := `SELECT id, "userID", field, old_value, new_value, COALESCE(run_id, ''), changed_at
+		FROM pickemcli_userstats_audit WHERE "userID" = $1`
+	args := []interface{}{filter.UserID}
+	if len(filter.Fields) > 0 {
+		args = append(args, pq.Array(filter.Fields))
+		query += fmt.Sprintf(" AND field = ANY($%d)", len(args))
+	}
+	query += " ORDER BY changed_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if isUndefinedTable(err) {
+		// Nothing has been upserted since auditing was added
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying user stats history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var old, updated sql.NullString
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Field, &old, &updated, &e.RunID, &e.ChangedAt); err != nil {
+			return nil, fmt.Errorf("error scanning user stats history: %w", err)
+		}
+		if old.Valid {
+			e.Old = StringPtr(old.String)
+		}
+		if updated.Valid {
+			e.New = StringPtr(updated.String)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading user stats history: %w", err)
+	}
+	return entries, nil
+}
+
+// PruneHistory deletes the audit entries recorded before cutoff and
+// returns how many were deleted
+func PruneHistory(ctx context.Context, db *sql.DB, cutoff time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM pickemcli_userstats_audit WHERE changed_at < $1`, cutoff)
+	if isUndefinedTable(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error pruning user stats history: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// isUndefinedTable reports whether err is Postgres' undefined_table error
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
@@ -0,0 +1,146 @@
+package dbUtil
+
+import (
+	"context"
+	"sync"
+)
+
+// CachedStore wraps a Store and reads each of the picks, games, season
+// points and emails at most once, answering filtered reads from the cached
+// rows. It is meant to live for one collection run, so every collector
+// shares a single scan of pickem_api_gamepicks. Writes that change cached
+// rows (pick grades, winner flags) drop the affected cache.
+type CachedStore struct {
+	Store
+
+	mu     sync.Mutex
+	picks  []Pick
+	games  []Game
+	points []SeasonPoints
+	emails map[string]string
+}
+
+// NewCachedStore returns a CachedStore reading through store
+func NewCachedStore(store Store) *CachedStore {
+	return &CachedStore{Store: store}
+}
+
+// Picks returns the picks matching filter from a single read of every pick
+func (s *CachedStore) Picks(ctx context.Context, filter PickFilter) ([]Pick, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.picks == nil {
+		picks, err := s.Store.Picks(ctx, PickFilter{})
+		if err != nil {
+			return nil, err
+		}
+		s.picks = append([]Pick{}, picks...)
+	}
+
+	var picks []Pick
+	for _, p := range s.picks {
+		if filter.UID != "" && p.UID != filter.UID {
+			continue
+		}
+		if filter.Season != "" && p.Season != filter.Season {
+			continue
+		}
+		picks = append(picks, p)
+	}
+	return picks, nil
+}
+
+// Games returns the games matching filter from a single read of every game
+func (s *CachedStore) Games(ctx context.Context, filter GameFilter) ([]Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.games == nil {
+		games, err := s.Store.Games(ctx, GameFilter{})
+		if err != nil {
+			return nil, err
+		}
+		s.games = append([]Game{}, games...)
+	}
+
+	var games []Game
+	for _, g := range s.games {
+		if filter.Season != "" && g.Season != filter.Season {
+			continue
+		}
+		if filter.ScoredOnly && !g.Scored {
+			continue
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+// SeasonPoints returns the season points rows for season, or all seasons,
+// from a single read of every row
+func (s *CachedStore) SeasonPoints(ctx context.Context, season string) ([]SeasonPoints, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.points == nil {
+		points, err := s.Store.SeasonPoints(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		s.points = append([]SeasonPoints{}, points...)
+	}
+
+	var points []SeasonPoints
+	for _, sp := range s.points {
+		if season != "" && sp.Season != season {
+			continue
+		}
+		points = append(points, sp)
+	}
+	return points, nil
+}
+
+// UserEmails returns a copy of every user's email, read once
+func (s *CachedStore) UserEmails(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emails == nil {
+		emails, err := s.Store.UserEmails(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.emails = emails
+	}
+
+	emails := make(map[string]string, len(s.emails))
+	for uid, email := range s.emails {
+		emails[uid] = email
+	}
+	return emails, nil
+}
+
+// UpdateWeekWinners writes updates and drops the cached season points
+func (s *CachedStore) UpdateWeekWinners(ctx context.Context, updates []WeekWinnerUpdate) error {
+	s.mu.Lock()
+	s.points = nil
+	s.mu.Unlock()
+	return s.Store.UpdateWeekWinners(ctx, updates)
+}
+
+// UpdateYearWinners writes updates and drops the cached season points
+func (s *CachedStore) UpdateYearWinners(ctx context.Context, updates []YearWinnerUpdate) error {
+	s.mu.Lock()
+	s.points = nil
+	s.mu.Unlock()
+	return s.Store.UpdateYearWinners(ctx, updates)
+}
+
+// UpdatePickGrades writes grades and drops the cached picks
+func (s *CachedStore) UpdatePickGrades(ctx context.Context, grades []PickGrade) error {
+	s.mu.Lock()
+	s.picks = nil
+	s.mu.Unlock()
+	return s.Store.UpdatePickGrades(ctx, grades)
+}
@@ -0,0 +1,80 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// GameConsensus is how the league picked one game, as stored in
+// pickemcli_game_consensus. The percentages are whole percentages of
+// Picks, every pick made on the game.
+type GameConsensus struct {
+	GameID      string
+	Season      string
+	Week        int
+	HomeTeam    string
+	AwayTeam    string
+	Picks       int
+	HomePicks   int
+	AwayPicks   int
+	HomePercent int
+	AwayPercent int
+}
+
+// consensusColumns lists the pickemcli_game_consensus columns in the same
+// order as the values returned by insertArgs
+var consensusColumns = []string{
+	"gameID", "season", "week", "homeTeam", "awayTeam", "picks",
+	"homePicks", "awayPicks", "homePercent", "awayPercent",
+}
+
+var insertConsensusQuery = func() string {
+	quoted := make([]string, len(consensusColumns))
+	placeholders := make([]string, len(consensusColumns))
+	for i, col := range consensusColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf(`INSERT INTO pickemcli_game_consensus (%s) VALUES (%s)`,
+		strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+}()
+
+// insertArgs returns the statement arguments for c, in consensusColumns order
+func (c *GameConsensus) insertArgs() []interface{} {
+	return []interface{}{
+		c.GameID, c.Season, c.Week, c.HomeTeam, c.AwayTeam, c.Picks,
+		c.HomePicks, c.AwayPicks, c.HomePercent, c.AwayPercent,
+	}
+}
+
+// ReplaceGameConsensus replaces every pickemcli_game_consensus row of
+// season with rows in a single transaction
+func ReplaceGameConsensus(ctx context.Context, db *sql.DB, season string, rows []GameConsensus) error {
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pickemcli_game_consensus WHERE season = $1`, season); err != nil {
+			return fmt.Errorf("error clearing %s game consensus: %w", season, err)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, insertConsensusQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing game consensus insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i := range rows {
+			if _, err := stmt.ExecContext(ctx, rows[i].insertArgs()...); err != nil {
+				return fmt.Errorf("error writing consensus for game %s: %w", rows[i].GameID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %d %s game consensus rows", len(rows), season)
+	return nil
+}
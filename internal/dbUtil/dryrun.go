@@ -0,0 +1,136 @@
+package dbUtil
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DryRunStore wraps a Store, passing reads through and holding writes back.
+// The UserStats upserts are merged in memory so that Diff can compare them
+// with the rows currently stored.
+type DryRunStore struct {
+	Store
+
+	mu      sync.Mutex
+	pending map[string]*UserStats
+	// SeasonRows counts the by-season history rows that would be written
+	SeasonRows int
+}
+
+// NewDryRunStore returns a DryRunStore reading from store
+func NewDryRunStore(store Store) *DryRunStore {
+	return &DryRunStore{Store: store, pending: make(map[string]*UserStats)}
+}
+
+// UpsertUserStats records batch without writing it
+func (s *DryRunStore) UpsertUserStats(ctx context.Context, batch []*UserStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stats := range batch {
+		if existing, ok := s.pending[stats.UserID]; ok {
+			existing.Merge(stats)
+			continue
+		}
+		copied := *stats
+		s.pending[stats.UserID] = &copied
+	}
+	return nil
+}
+
+// UpsertSeasonStats counts batch without writing it
+func (s *DryRunStore) UpsertSeasonStats(ctx context.Context, batch []*SeasonStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.SeasonRows += len(batch)
+	return nil
+}
+
+// ReplaceLeaderboard discards entries without writing them
+func (s *DryRunStore) ReplaceLeaderboard(ctx context.Context, scope string, entries []LeaderboardEntry) error {
+	return nil
+}
+
+// ReplaceStreaks discards records without writing them
+func (s *DryRunStore) ReplaceStreaks(ctx context.Context, scope string, records []StreakRecord) error {
+	return nil
+}
+
+// ReplaceTeamAccuracy discards rows without writing them
+func (s *DryRunStore) ReplaceTeamAccuracy(ctx context.Context, scope string, rows []TeamAccuracy) error {
+	return nil
+}
+
+// ReplaceGameConsensus discards rows without writing them
+func (s *DryRunStore) ReplaceGameConsensus(ctx context.Context, season string, rows []GameConsensus) error {
+	return nil
+}
+
+// UpdateWeekWinners discards updates without writing them
+func (s *DryRunStore) UpdateWeekWinners(ctx context.Context, updates []WeekWinnerUpdate) error {
+	return nil
+}
+
+// UpdateYearWinners discards updates without writing them
+func (s *DryRunStore) UpdateYearWinners(ctx context.Context, updates []YearWinnerUpdate) error {
+	return nil
+}
+
+// UpdatePickGrades discards grades without writing them
+func (s *DryRunStore) UpdatePickGrades(ctx context.Context, grades []PickGrade) error {
+	return nil
+}
+
+// FieldChange is one column that an upsert would change
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// UserDiff is every change an upsert would make to one user's row
+type UserDiff struct {
+	UserID string `json:"userID"`
+	// Created is set when the user has no pickem_api_userstats row yet
+	Created bool          `json:"created"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Diff compares the held back UserStats upserts with the rows currently in
+// the wrapped store and returns the users whose row would change, ordered
+// by user ID. Columns an upsert leaves unset keep their stored value, so
+// they never show up as changes.
+func (s *DryRunStore) Diff(ctx context.Context) ([]UserDiff, error) {
+	current, err := s.Store.UserStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]*UserStats, len(current))
+	for _, stats := range current {
+		stored[stats.UserID] = stats
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var diffs []UserDiff
+	for _, uid := range sortedUserIDs(s.pending) {
+		old, ok := stored[uid]
+		diff := UserDiff{UserID: uid, Created: !ok, Changes: old.Changes(s.pending[uid])}
+		if diff.Created || len(diff.Changes) > 0 {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+func sortedUserIDs(m map[string]*UserStats) []string {
+	ids := make([]string, 0, len(m))
+	for uid := range m {
+		ids = append(ids, uid)
+	}
+	sort.Strings(ids)
+	return ids
+}
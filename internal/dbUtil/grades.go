@@ -0,0 +1,44 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// PickGrade sets pick_correct of one pickem_api_gamepicks row. A nil
+// Correct clears the grade.
+type PickGrade struct {
+	PickID  string
+	Correct *bool
+}
+
+// UpdatePickGrades writes grades to pickem_api_gamepicks in a single
+// transaction
+func UpdatePickGrades(ctx context.Context, db *sql.DB, grades []PickGrade) error {
+	if len(grades) == 0 {
+		return nil
+	}
+
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `UPDATE pickem_api_gamepicks SET pick_correct = $1 WHERE id = $2`)
+		if err != nil {
+			return fmt.Errorf("error preparing pick grade update: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, g := range grades {
+			if _, err := stmt.ExecContext(ctx, g.Correct, g.PickID); err != nil {
+				return fmt.Errorf("error grading pick %s: %w", g.PickID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Graded %d picks", len(grades))
+	return nil
+}
@@ -0,0 +1,90 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// LeaderboardAllTime is the LeaderboardEntry.Scope of the all-time board
+const LeaderboardAllTime = "all"
+
+// LeaderboardEntry is one user's place on a leaderboard, as stored in
+// pickemcli_leaderboard. Scope is a YYZZ season or LeaderboardAllTime.
+type LeaderboardEntry struct {
+	Scope     string
+	UserID    string
+	UserEmail string
+	Rank      int
+	// Movement is how many places the user rose (negative: fell) since
+	// the previous week; nil when there is nothing to compare with
+	Movement *int
+	// Gap is how far behind the leader the user is, in RankedBy units
+	Gap          float64
+	RankedBy     string
+	WeeksWon     int
+	CorrectPicks int
+	TotalPicks   int
+	PickPercent  int
+	PerfectWeeks int
+	// Week is the last week the board counts, in the newest season
+	Week int
+}
+
+// leaderboardColumns lists the pickemcli_leaderboard columns in the same
+// order as the values returned by insertArgs
+var leaderboardColumns = []string{
+	"scope", "userID", "userEmail", "rank", "movement", "gap", "rankedBy",
+	"weeksWon", "correctPicks", "totalPicks", "pickPercent", "perfectWeeks", "week",
+}
+
+var insertLeaderboardQuery = func() string {
+	quoted := make([]string, len(leaderboardColumns))
+	placeholders := make([]string, len(leaderboardColumns))
+	for i, col := range leaderboardColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf(`INSERT INTO pickemcli_leaderboard (%s) VALUES (%s)`,
+		strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+}()
+
+// insertArgs returns the statement arguments for e, in leaderboardColumns order
+func (e *LeaderboardEntry) insertArgs() []interface{} {
+	return []interface{}{
+		e.Scope, e.UserID, e.UserEmail, e.Rank, e.Movement, e.Gap, e.RankedBy,
+		e.WeeksWon, e.CorrectPicks, e.TotalPicks, e.PickPercent, e.PerfectWeeks, e.Week,
+	}
+}
+
+// ReplaceLeaderboard replaces every pickemcli_leaderboard row of scope
+// with entries in a single transaction, so the site never sees a board
+// half written
+func ReplaceLeaderboard(ctx context.Context, db *sql.DB, scope string, entries []LeaderboardEntry) error {
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pickemcli_leaderboard WHERE scope = $1`, scope); err != nil {
+			return fmt.Errorf("error clearing %s leaderboard: %w", scope, err)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, insertLeaderboardQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing leaderboard insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i := range entries {
+			if _, err := stmt.ExecContext(ctx, entries[i].insertArgs()...); err != nil {
+				return fmt.Errorf("error writing %s leaderboard for userID %s: %w", scope, entries[i].UserID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %d %s leaderboard entries", len(entries), scope)
+	return nil
+}
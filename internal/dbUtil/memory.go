@@ -0,0 +1,357 @@
+package dbUtil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store over in-memory fixture data. Upserted
+// UserStats are merged into Stats the same way PostgresStore merges them
+// into pickem_api_userstats.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	PickRows         []Pick
+	GameRows         []Game
+	SeasonPointsRows []SeasonPoints
+	Emails           map[string]string
+	Stats            map[string]*UserStats
+	// SeasonStats is keyed by SeasonStatsKey
+	SeasonStats map[string]*SeasonStats
+	// Audit collects the field changes made by UpsertUserStats, like
+	// pickemcli_userstats_audit
+	Audit []AuditEntry
+	// Leaderboards is keyed by LeaderboardEntry.Scope
+	Leaderboards map[string][]LeaderboardEntry
+	// Streaks is keyed by StreakRecord.Scope
+	Streaks map[string][]StreakRecord
+	// TeamAccuracy is keyed by TeamAccuracy.Scope
+	TeamAccuracy map[string][]TeamAccuracy
+	// Consensus is keyed by GameConsensus.Season
+	Consensus map[string][]GameConsensus
+}
+
+// SeasonStatsKey returns the MemoryStore.SeasonStats key for a user and season
+func SeasonStatsKey(userID, season string) string {
+	return userID + "/" + season
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		Emails:       make(map[string]string),
+		Stats:        make(map[string]*UserStats),
+		SeasonStats:  make(map[string]*SeasonStats),
+		Leaderboards: make(map[string][]LeaderboardEntry),
+		Streaks:      make(map[string][]StreakRecord),
+		TeamAccuracy: make(map[string][]TeamAccuracy),
+		Consensus:    make(map[string][]GameConsensus),
+	}
+}
+
+// Picks returns the fixture picks matching filter
+func (s *MemoryStore) Picks(ctx context.Context, filter PickFilter) ([]Pick, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var picks []Pick
+	for _, p := range s.PickRows {
+		if filter.UID != "" && p.UID != filter.UID {
+			continue
+		}
+		if filter.Season != "" && p.Season != filter.Season {
+			continue
+		}
+		picks = append(picks, p)
+	}
+	return picks, nil
+}
+
+// PickTotals counts the fixture picks per user, as the grouped query in
+// PostgresStore.PickTotals does
+func (s *MemoryStore) PickTotals(ctx context.Context, season string) ([]PickTotals, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byUID := make(map[string]*PickTotals)
+	for _, p := range s.PickRows {
+		if p.Season == "" {
+			continue
+		}
+		t, ok := byUID[p.UID]
+		if !ok {
+			t = &PickTotals{UID: p.UID}
+			byUID[p.UID] = t
+		}
+		t.Picks++
+		if p.Correct {
+			t.Correct++
+		}
+		if p.Season == season {
+			t.SeasonPicks++
+			if p.Correct {
+				t.SeasonCorrect++
+			}
+		}
+	}
+
+	totals := make([]PickTotals, 0, len(byUID))
+	for _, t := range byUID {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].UID < totals[j].UID })
+	return totals, nil
+}
+
+// Games returns the fixture games matching filter
+func (s *MemoryStore) Games(ctx context.Context, filter GameFilter) ([]Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var games []Game
+	for _, g := range s.GameRows {
+		if g.Season == "" {
+			continue
+		}
+		if filter.Season != "" && g.Season != filter.Season {
+			continue
+		}
+		if filter.ScoredOnly && !g.Scored {
+			continue
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+// GameSeasons returns the distinct seasons of the fixture games
+func (s *MemoryStore) GameSeasons(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var seasons []string
+	for _, g := range s.GameRows {
+		if g.Season == "" || seen[g.Season] {
+			continue
+		}
+		seen[g.Season] = true
+		seasons = append(seasons, g.Season)
+	}
+	return seasons, nil
+}
+
+// SeasonPoints returns the fixture season points for season, or all seasons
+func (s *MemoryStore) SeasonPoints(ctx context.Context, season string) ([]SeasonPoints, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var points []SeasonPoints
+	for _, sp := range s.SeasonPointsRows {
+		if sp.Season == "" {
+			continue
+		}
+		if season != "" && sp.Season != season {
+			continue
+		}
+		points = append(points, sp)
+	}
+	return points, nil
+}
+
+// UserEmails returns a copy of the fixture emails
+func (s *MemoryStore) UserEmails(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	emails := make(map[string]string, len(s.Emails))
+	for uid, email := range s.Emails {
+		emails[uid] = email
+	}
+	return emails, nil
+}
+
+// UserStats returns copies of the stored UserStats, ordered by user ID
+func (s *MemoryStore) UserStats(ctx context.Context) ([]*UserStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make([]*UserStats, 0, len(s.Stats))
+	for _, stats := range s.Stats {
+		copied := *stats
+		all = append(all, &copied)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].UserID < all[j].UserID })
+	return all, nil
+}
+
+// UserSeasonStats returns copies of season's SeasonStats, ordered by user ID
+func (s *MemoryStore) UserSeasonStats(ctx context.Context, season string) ([]*SeasonStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var all []*SeasonStats
+	for _, stats := range s.SeasonStats {
+		if stats.Season == season {
+			copied := *stats
+			all = append(all, &copied)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].UserID < all[j].UserID })
+	return all, nil
+}
+
+// UpsertUserStats merges batch into Stats, keeping stored values for nil
+// fields, and records the changed fields in Audit
+func (s *MemoryStore) UpsertUserStats(ctx context.Context, batch []*UserStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runID := RunID(ctx)
+	now := time.Now()
+	for _, stats := range batch {
+		existing, ok := s.Stats[stats.UserID]
+		for _, e := range auditEntries(stats.UserID, runID, existing.Changes(stats)) {
+			e.ID = int64(len(s.Audit) + 1)
+			e.ChangedAt = now
+			s.Audit = append(s.Audit, e)
+		}
+		if !ok {
+			copied := *stats
+			s.Stats[stats.UserID] = &copied
+			continue
+		}
+		existing.Merge(stats)
+	}
+	return nil
+}
+
+// UpsertSeasonStats merges batch into SeasonStats, keeping stored values for
+// nil fields
+func (s *MemoryStore) UpsertSeasonStats(ctx context.Context, batch []*SeasonStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stats := range batch {
+		key := SeasonStatsKey(stats.UserID, stats.Season)
+		existing, ok := s.SeasonStats[key]
+		if !ok {
+			copied := *stats
+			s.SeasonStats[key] = &copied
+			continue
+		}
+		existing.Merge(stats)
+	}
+	return nil
+}
+
+// ReplaceLeaderboard replaces Leaderboards[scope] with a copy of entries
+func (s *MemoryStore) ReplaceLeaderboard(ctx context.Context, scope string, entries []LeaderboardEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Leaderboards[scope] = append([]LeaderboardEntry(nil), entries...)
+	return nil
+}
+
+// ReplaceStreaks stores records in Streaks
+func (s *MemoryStore) ReplaceStreaks(ctx context.Context, scope string, records []StreakRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Streaks[scope] = append([]StreakRecord(nil), records...)
+	return nil
+}
+
+// ReplaceTeamAccuracy stores rows in TeamAccuracy
+func (s *MemoryStore) ReplaceTeamAccuracy(ctx context.Context, scope string, rows []TeamAccuracy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.TeamAccuracy[scope] = append([]TeamAccuracy(nil), rows...)
+	return nil
+}
+
+// ReplaceGameConsensus stores rows in Consensus
+func (s *MemoryStore) ReplaceGameConsensus(ctx context.Context, season string, rows []GameConsensus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Consensus[season] = append([]GameConsensus(nil), rows...)
+	return nil
+}
+
+// UpdateWeekWinners sets the flags in SeasonPointsRows. Like the Postgres
+// version it fails, changing nothing, if a row does not exist.
+func (s *MemoryStore) UpdateWeekWinners(ctx context.Context, updates []WeekWinnerUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make([]int, len(updates))
+	for i, u := range updates {
+		if u.Week < 1 || u.Week > SeasonWeeks {
+			return fmt.Errorf("week %d has no week_N_winner column", u.Week)
+		}
+		rows[i] = s.seasonPointsRow(u.UserID, u.Season)
+		if rows[i] < 0 {
+			return fmt.Errorf("no season %s points row for userID %s", u.Season, u.UserID)
+		}
+	}
+	for i, u := range updates {
+		s.SeasonPointsRows[rows[i]].WeekWinner[u.Week-1] = u.Winner
+	}
+	return nil
+}
+
+// UpdateYearWinners sets the flags in SeasonPointsRows. Like the Postgres
+// version it fails, changing nothing, if a row does not exist.
+func (s *MemoryStore) UpdateYearWinners(ctx context.Context, updates []YearWinnerUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make([]int, len(updates))
+	for i, u := range updates {
+		rows[i] = s.seasonPointsRow(u.UserID, u.Season)
+		if rows[i] < 0 {
+			return fmt.Errorf("no season %s points row for userID %s", u.Season, u.UserID)
+		}
+	}
+	for i, u := range updates {
+		s.SeasonPointsRows[rows[i]].YearWinner = u.Winner
+	}
+	return nil
+}
+
+// seasonPointsRow returns the index in SeasonPointsRows of the user's row
+// for season, or -1
+func (s *MemoryStore) seasonPointsRow(userID, season string) int {
+	for i, sp := range s.SeasonPointsRows {
+		if sp.UserID == userID && sp.Season == season {
+			return i
+		}
+	}
+	return -1
+}
+
+// UpdatePickGrades sets Correct and Graded on the matching PickRows
+func (s *MemoryStore) UpdatePickGrades(ctx context.Context, grades []PickGrade) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byID := make(map[string]*bool, len(grades))
+	for _, g := range grades {
+		byID[g.PickID] = g.Correct
+	}
+	for i, p := range s.PickRows {
+		correct, ok := byID[p.ID]
+		if !ok {
+			continue
+		}
+		s.PickRows[i].Graded = correct != nil
+		s.PickRows[i].Correct = correct != nil && *correct
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ownedSchema creates the tables pickemcli owns, as opposed to the Django
+// tables it reads. They are all prefixed pickemcli_ and every statement
+// must be safe to run repeatedly.
+var ownedSchema = []string{
+	`CREATE TABLE IF NOT EXISTS pickemcli_userstats_by_season (
+		"userID" text NOT NULL,
+		season text NOT NULL,
+		"userEmail" text NOT NULL DEFAULT '',
+		"weeksWon" integer,
+		"pickPercent" integer,
+		"correctPickTotal" integer,
+		"totalPicks" integer,
+		"mostPicked" text,
+		"leastPicked" text,
+		"missedPicks" integer,
+		"perfectWeeks" integer,
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY ("userID", season)
+	)`,
+	`CREATE TABLE IF NOT EXISTS pickemcli_userstats_audit (
+		id bigserial PRIMARY KEY,
+		"userID" text NOT NULL,
+		field text NOT NULL,
+		old_value text,
+		new_value text,
+		run_id text,
+		changed_at timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS pickemcli_userstats_audit_user_idx
+		ON pickemcli_userstats_audit ("userID", changed_at)`,
+	`CREATE INDEX IF NOT EXISTS pickemcli_userstats_audit_changed_at_idx
+		ON pickemcli_userstats_audit (changed_at)`,
+	`CREATE TABLE IF NOT EXISTS pickemcli_snapshots (
+		id bigserial PRIMARY KEY,
+		created_at timestamptz NOT NULL DEFAULT now(),
+		reason text NOT NULL,
+		run_id text,
+		row_count integer NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS pickemcli_snapshot_rows (
+		snapshot_id bigint NOT NULL REFERENCES pickemcli_snapshots (id) ON DELETE CASCADE,
+		"userID" text NOT NULL,
+		data jsonb NOT NULL,
+		PRIMARY KEY (snapshot_id, "userID")
+	)`,
+	`CREATE TABLE IF NOT EXISTS pickemcli_leaderboard (
+		scope text NOT NULL,
+		"userID" text NOT NULL,
+		"userEmail" text NOT NULL DEFAULT '',
+		rank integer NOT NULL,
+		movement integer,
+		gap double precision NOT NULL,
+		"rankedBy" text NOT NULL,
+		"weeksWon" integer NOT NULL,
+		"correctPicks" integer NOT NULL,
+		"totalPicks" integer NOT NULL,
+		"pickPercent" integer NOT NULL,
+		"perfectWeeks" integer NOT NULL,
+		week integer NOT NULL,
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (scope, "userID")
+	)`,
+	`CREATE TABLE IF NOT EXISTS pickemcli_streaks (
+		scope text NOT NULL,
+		"userID" text NOT NULL,
+		"userEmail" text NOT NULL DEFAULT '',
+		"longestCorrect" integer NOT NULL,
+		"currentCorrect" integer NOT NULL,
+		"longestWrong" integer NOT NULL,
+		"longestWeeksWon" integer NOT NULL,
+		"currentWeeksWon" integer NOT NULL DEFAULT 0,
+		"longestNoMissed" integer NOT NULL,
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (scope, "userID")
+	)`,
+	`ALTER TABLE pickemcli_streaks ADD COLUMN IF NOT EXISTS "currentWeeksWon" integer NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS pickemcli_team_accuracy (
+		scope text NOT NULL,
+		"userID" text NOT NULL,
+		team text NOT NULL,
+		picks integer NOT NULL,
+		correct integer NOT NULL,
+		accuracy integer NOT NULL,
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (scope, "userID", team)
+	)`,
+	`CREATE TABLE IF NOT EXISTS pickemcli_game_consensus (
+		"gameID" text PRIMARY KEY,
+		season text NOT NULL,
+		week integer NOT NULL,
+		"homeTeam" text NOT NULL DEFAULT '',
+		"awayTeam" text NOT NULL DEFAULT '',
+		picks integer NOT NULL,
+		"homePicks" integer NOT NULL,
+		"awayPicks" integer NOT NULL,
+		"homePercent" integer NOT NULL,
+		"awayPercent" integer NOT NULL,
+		updated_at timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS pickemcli_game_consensus_season_idx
+		ON pickemcli_game_consensus (season, week)`,
+}
+
+// Migrate creates any pickemcli-owned tables that do not exist yet
+func Migrate(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range ownedSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error creating pickemcli tables: %w", err)
+		}
+	}
+	return nil
+}
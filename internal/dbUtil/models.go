@@ -1,6 +1,8 @@
 package dbUtil
 
 import (
+	"strings"
+
 	"github.com/google/uuid"
 )
 
@@ -37,3 +39,232 @@ func NewUserStats(userID, userEmail string) *UserStats {
 		UserEmail: userEmail,
 	}
 }
+
+// Merge copies the non-nil fields of other (and its email, when set) onto
+// stats, mirroring how an upsert updates an existing record
+func (stats *UserStats) Merge(other *UserStats) {
+	if other.UserEmail != "" {
+		stats.UserEmail = other.UserEmail
+	}
+	mergeInt(&stats.WeeksWonSeason, other.WeeksWonSeason)
+	mergeInt(&stats.WeeksWonTotal, other.WeeksWonTotal)
+	mergeInt(&stats.PickPercentSeason, other.PickPercentSeason)
+	mergeInt(&stats.PickPercentTotal, other.PickPercentTotal)
+	mergeInt(&stats.CorrectPickTotalSeason, other.CorrectPickTotalSeason)
+	mergeInt(&stats.CorrectPickTotalTotal, other.CorrectPickTotalTotal)
+	mergeInt(&stats.TotalPicksSeason, other.TotalPicksSeason)
+	mergeInt(&stats.TotalPicksTotal, other.TotalPicksTotal)
+	mergeString(&stats.MostPickedSeason, other.MostPickedSeason)
+	mergeString(&stats.MostPickedTotal, other.MostPickedTotal)
+	mergeString(&stats.LeastPickedSeason, other.LeastPickedSeason)
+	mergeString(&stats.LeastPickedTotal, other.LeastPickedTotal)
+	mergeInt(&stats.SeasonsWon, other.SeasonsWon)
+	mergeInt(&stats.MissedPicksSeason, other.MissedPicksSeason)
+	mergeInt(&stats.MissedPicksTotal, other.MissedPicksTotal)
+	mergeInt(&stats.PerfectWeeksSeason, other.PerfectWeeksSeason)
+	mergeInt(&stats.PerfectWeeksTotal, other.PerfectWeeksTotal)
+}
+
+// StatField is one column of a UserStats record. Value is nil when the
+// column is unset, otherwise an int or a string.
+type StatField struct {
+	Name  string
+	Value interface{}
+}
+
+// StatFields returns the email and statistic columns of stats, in
+// pickem_api_userstats column order
+func (stats *UserStats) StatFields() []StatField {
+	var email interface{}
+	if stats.UserEmail != "" {
+		email = stats.UserEmail
+	}
+	return []StatField{
+		{"userEmail", email},
+		{"weeksWonSeason", intValue(stats.WeeksWonSeason)},
+		{"weeksWonTotal", intValue(stats.WeeksWonTotal)},
+		{"pickPercentSeason", intValue(stats.PickPercentSeason)},
+		{"pickPercentTotal", intValue(stats.PickPercentTotal)},
+		{"correctPickTotalSeason", intValue(stats.CorrectPickTotalSeason)},
+		{"correctPickTotalTotal", intValue(stats.CorrectPickTotalTotal)},
+		{"totalPicksSeason", intValue(stats.TotalPicksSeason)},
+		{"totalPicksTotal", intValue(stats.TotalPicksTotal)},
+		{"mostPickedSeason", stringValue(stats.MostPickedSeason)},
+		{"mostPickedTotal", stringValue(stats.MostPickedTotal)},
+		{"leastPickedSeason", stringValue(stats.LeastPickedSeason)},
+		{"leastPickedTotal", stringValue(stats.LeastPickedTotal)},
+		{"seasonsWon", intValue(stats.SeasonsWon)},
+		{"missedPicksSeason", intValue(stats.MissedPicksSeason)},
+		{"missedPicksTotal", intValue(stats.MissedPicksTotal)},
+		{"perfectWeeksSeason", intValue(stats.PerfectWeeksSeason)},
+		{"perfectWeeksTotal", intValue(stats.PerfectWeeksTotal)},
+	}
+}
+
+func intValue(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func stringValue(v *string) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func mergeInt(dst **int, src *int) {
+	if src != nil {
+		*dst = IntPtr(*src)
+	}
+}
+
+func mergeString(dst **string, src *string) {
+	if src != nil {
+		*dst = StringPtr(*src)
+	}
+}
+
+// SeasonWeeks is the number of regular season weeks tracked by the
+// week_N_winner columns of pickem_api_userseasonpoints
+const SeasonWeeks = 18
+
+// Pick represents a row of pickem_api_gamepicks
+type Pick struct {
+	ID      string `db:"id"`
+	UID     string `db:"uid"`
+	Season  string `db:"gameseason"` // empty when gameseason is NULL
+	Week    int    `db:"gameWeek"`
+	GameID  string `db:"pick_game_id"`
+	Team    string `db:"pick"`
+	Correct bool   `db:"pick_correct"` // NULL (not yet graded) reads as false
+	Graded  bool   // false when pick_correct is NULL
+}
+
+// PickTotals is one user's pick counts, all time and for one season.
+// Picks without a season are not counted.
+type PickTotals struct {
+	UID           string
+	Correct       int
+	Picks         int
+	SeasonCorrect int
+	SeasonPicks   int
+}
+
+// Game represents a row of pickem_api_gamesandscores
+type Game struct {
+	ID        string `db:"id"`
+	Season    string `db:"gameseason"`
+	Week      int    `db:"gameWeek"`
+	Scored    bool   `db:"gameScored"`
+	HomeTeam  string `db:"homeTeam"`
+	AwayTeam  string `db:"awayTeam"`
+	HomeScore *int   `db:"homeTeamScore"` // nil until the score is entered
+	AwayScore *int   `db:"awayTeamScore"`
+}
+
+// TeamKey normalises a team name for matching picks against games and
+// each other. Team names are typed in by hand, so surrounding space and
+// case are ignored.
+func TeamKey(team string) string {
+	return strings.ToLower(strings.TrimSpace(team))
+}
+
+// SameTeam reports whether a and b name the same team. An empty name
+// matches nothing.
+func SameTeam(a, b string) bool {
+	return TeamKey(b) != "" && TeamKey(a) == TeamKey(b)
+}
+
+// SeasonPoints represents a row of pickem_api_userseasonpoints
+type SeasonPoints struct {
+	UserID     string `db:"userID"`
+	Season     string `db:"gameseason"`
+	WeekWinner [SeasonWeeks]bool
+	YearWinner bool `db:"year_winner"`
+}
+
+// WeeksWon returns the number of weeks flagged as won in the season
+func (sp SeasonPoints) WeeksWon() int {
+	won := 0
+	for _, w := range sp.WeekWinner {
+		if w {
+			won++
+		}
+	}
+	return won
+}
+
+// SeasonStats represents a row of pickemcli_userstats_by_season: one user's
+// statistics for one season. It holds the same values as the *Season
+// fields of UserStats, kept for every season rather than just the current
+// one.
+type SeasonStats struct {
+	UserID       string  `db:"userID"`
+	Season       string  `db:"season"`
+	UserEmail    string  `db:"userEmail"`
+	WeeksWon     *int    `db:"weeksWon"`
+	PickPercent  *int    `db:"pickPercent"`
+	CorrectPicks *int    `db:"correctPickTotal"`
+	TotalPicks   *int    `db:"totalPicks"`
+	MostPicked   *string `db:"mostPicked"`
+	LeastPicked  *string `db:"leastPicked"`
+	MissedPicks  *int    `db:"missedPicks"`
+	PerfectWeeks *int    `db:"perfectWeeks"`
+}
+
+// NewSeasonStats takes the *Season fields of stats as the statistics for
+// season
+func NewSeasonStats(stats *UserStats, season string) *SeasonStats {
+	return &SeasonStats{
+		UserID:       stats.UserID,
+		Season:       season,
+		UserEmail:    stats.UserEmail,
+		WeeksWon:     stats.WeeksWonSeason,
+		PickPercent:  stats.PickPercentSeason,
+		CorrectPicks: stats.CorrectPickTotalSeason,
+		TotalPicks:   stats.TotalPicksSeason,
+		MostPicked:   stats.MostPickedSeason,
+		LeastPicked:  stats.LeastPickedSeason,
+		MissedPicks:  stats.MissedPicksSeason,
+		PerfectWeeks: stats.PerfectWeeksSeason,
+	}
+}
+
+// StatFields returns the email and statistic columns of stats, in
+// pickemcli_userstats_by_season column order
+func (stats *SeasonStats) StatFields() []StatField {
+	var email interface{}
+	if stats.UserEmail != "" {
+		email = stats.UserEmail
+	}
+	return []StatField{
+		{"userEmail", email},
+		{"weeksWon", intValue(stats.WeeksWon)},
+		{"pickPercent", intValue(stats.PickPercent)},
+		{"correctPickTotal", intValue(stats.CorrectPicks)},
+		{"totalPicks", intValue(stats.TotalPicks)},
+		{"mostPicked", stringValue(stats.MostPicked)},
+		{"leastPicked", stringValue(stats.LeastPicked)},
+		{"missedPicks", intValue(stats.MissedPicks)},
+		{"perfectWeeks", intValue(stats.PerfectWeeks)},
+	}
+}
+
+// Merge copies the non-nil fields of other (and its email, when set) onto
+// stats, mirroring how an upsert updates an existing record
+func (stats *SeasonStats) Merge(other *SeasonStats) {
+	if other.UserEmail != "" {
+		stats.UserEmail = other.UserEmail
+	}
+	mergeInt(&stats.WeeksWon, other.WeeksWon)
+	mergeInt(&stats.PickPercent, other.PickPercent)
+	mergeInt(&stats.CorrectPicks, other.CorrectPicks)
+	mergeInt(&stats.TotalPicks, other.TotalPicks)
+	mergeString(&stats.MostPicked, other.MostPicked)
+	mergeString(&stats.LeastPicked, other.LeastPicked)
+	mergeInt(&stats.MissedPicks, other.MissedPicks)
+	mergeInt(&stats.PerfectWeeks, other.PerfectWeeks)
+}
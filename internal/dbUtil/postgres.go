@@ -0,0 +1,422 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// queryer is the part of *sql.DB and *sql.Tx the reads need
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// ErrReadOnly is returned by writes to a read-only PostgresStore
+var ErrReadOnly = errors.New("store is read-only")
+
+// PostgresStore implements Store against the Django PostgreSQL database
+type PostgresStore struct {
+	db *sql.DB
+	// q runs the reads: db itself, or a read-only transaction on it
+	q        queryer
+	readOnly bool
+
+	// migrated records whether the pickemcli-owned tables have been
+	// created, which happens on the first write to one of them
+	mu       sync.Mutex
+	migrated bool
+}
+
+// NewPostgresStore returns a Store backed by db
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, q: db}
+}
+
+// NewReadOnlyStore starts a read-only, repeatable read transaction on db and
+// returns a Store whose reads all run in it, so they see one consistent
+// snapshot. Its writes fail with ErrReadOnly. The caller must roll back the
+// returned transaction when done.
+func NewReadOnlyStore(ctx context.Context, db *sql.DB) (*PostgresStore, *sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error starting read-only transaction: %w", err)
+	}
+	return &PostgresStore{db: db, q: tx, readOnly: true}, tx, nil
+}
+
+// Picks returns the picks matching filter in a single query
+func (s *PostgresStore) Picks(ctx context.Context, filter PickFilter) ([]Pick, error) {
+	query := `SELECT id::text, uid, gameseason, "gameWeek", pick_game_id, pick, pick_correct
+		FROM pickem_api_gamepicks`
+	var where []string
+	var args []interface{}
+	if filter.UID != "" {
+		args = append(args, filter.UID)
+		where = append(where, fmt.Sprintf("uid = $%d", len(args)))
+	}
+	if filter.Season != "" {
+		args = append(args, filter.Season)
+		where = append(where, fmt.Sprintf("gameseason = $%d", len(args)))
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying picks: %w", err)
+	}
+	defer rows.Close()
+
+	var picks []Pick
+	for rows.Next() {
+		var p Pick
+		var season, gameID, team sql.NullString
+		var week sql.NullInt64
+		var correct sql.NullBool
+		if err := rows.Scan(&p.ID, &p.UID, &season, &week, &gameID, &team, &correct); err != nil {
+			return nil, fmt.Errorf("error scanning pick: %w", err)
+		}
+		p.Correct = correct.Bool
+		p.Graded = correct.Valid
+		p.Season = season.String
+		p.Week = int(week.Int64)
+		p.GameID = gameID.String
+		p.Team = team.String
+		picks = append(picks, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading picks: %w", err)
+	}
+	return picks, nil
+}
+
+// PickTotals counts every user's picks in a single grouped query rather
+// than reading the picks table into memory
+func (s *PostgresStore) PickTotals(ctx context.Context, season string) ([]PickTotals, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT uid,
+			COUNT(*) FILTER (WHERE pick_correct),
+			COUNT(*),
+			COUNT(*) FILTER (WHERE pick_correct AND gameseason = $1),
+			COUNT(*) FILTER (WHERE gameseason = $1)
+		FROM pickem_api_gamepicks
+		WHERE gameseason IS NOT NULL
+		GROUP BY uid
+		ORDER BY uid`, season)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pick totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []PickTotals
+	for rows.Next() {
+		var t PickTotals
+		if err := rows.Scan(&t.UID, &t.Correct, &t.Picks, &t.SeasonCorrect, &t.SeasonPicks); err != nil {
+			return nil, fmt.Errorf("error scanning pick totals: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading pick totals: %w", err)
+	}
+	return totals, nil
+}
+
+// Games returns the games matching filter
+func (s *PostgresStore) Games(ctx context.Context, filter GameFilter) ([]Game, error) {
+	query := `SELECT id, gameseason, "gameWeek", COALESCE("gameScored", false),
+			"homeTeam", "awayTeam", "homeTeamScore", "awayTeamScore"
+		FROM pickem_api_gamesandscores
+		WHERE gameseason IS NOT NULL`
+	var args []interface{}
+	if filter.Season != "" {
+		args = append(args, filter.Season)
+		query += fmt.Sprintf(" AND gameseason = $%d", len(args))
+	}
+	if filter.ScoredOnly {
+		query += ` AND "gameScored" = true`
+	}
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying games: %w", err)
+	}
+	defer rows.Close()
+
+	var games []Game
+	for rows.Next() {
+		var g Game
+		var week, homeScore, awayScore sql.NullInt64
+		var homeTeam, awayTeam sql.NullString
+		if err := rows.Scan(&g.ID, &g.Season, &week, &g.Scored, &homeTeam, &awayTeam, &homeScore, &awayScore); err != nil {
+			return nil, fmt.Errorf("error scanning game: %w", err)
+		}
+		g.Week = int(week.Int64)
+		g.HomeTeam = homeTeam.String
+		g.AwayTeam = awayTeam.String
+		if homeScore.Valid {
+			g.HomeScore = IntPtr(int(homeScore.Int64))
+		}
+		if awayScore.Valid {
+			g.AwayScore = IntPtr(int(awayScore.Int64))
+		}
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading games: %w", err)
+	}
+	return games, nil
+}
+
+// GameSeasons returns every distinct season that has games
+func (s *PostgresStore) GameSeasons(ctx context.Context) ([]string, error) {
+	rows, err := s.q.QueryContext(ctx, `SELECT DISTINCT gameseason FROM pickem_api_gamesandscores WHERE gameseason IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying game seasons: %w", err)
+	}
+	defer rows.Close()
+
+	var seasons []string
+	for rows.Next() {
+		var season string
+		if err := rows.Scan(&season); err != nil {
+			return nil, fmt.Errorf("error scanning game season: %w", err)
+		}
+		seasons = append(seasons, season)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading game seasons: %w", err)
+	}
+	return seasons, nil
+}
+
+// SeasonPoints returns the season points rows for season, or all seasons
+func (s *PostgresStore) SeasonPoints(ctx context.Context, season string) ([]SeasonPoints, error) {
+	cols := []string{`"userID"`, "gameseason"}
+	for week := 1; week <= SeasonWeeks; week++ {
+		cols = append(cols, fmt.Sprintf(`COALESCE("week_%d_winner", false)`, week))
+	}
+	cols = append(cols, `COALESCE("year_winner", false)`)
+
+	query := fmt.Sprintf(`SELECT %s FROM pickem_api_userseasonpoints WHERE gameseason IS NOT NULL`,
+		strings.Join(cols, ", "))
+	var args []interface{}
+	if season != "" {
+		args = append(args, season)
+		query += " AND gameseason = $1"
+	}
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying season points: %w", err)
+	}
+	defer rows.Close()
+
+	var points []SeasonPoints
+	for rows.Next() {
+		var sp SeasonPoints
+		dest := []interface{}{&sp.UserID, &sp.Season}
+		for i := range sp.WeekWinner {
+			dest = append(dest, &sp.WeekWinner[i])
+		}
+		dest = append(dest, &sp.YearWinner)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning season points: %w", err)
+		}
+		points = append(points, sp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading season points: %w", err)
+	}
+	return points, nil
+}
+
+// UserEmails returns every user's email from account_emailaddress. When a
+// user has several addresses the first one returned is kept.
+func (s *PostgresStore) UserEmails(ctx context.Context) (map[string]string, error) {
+	rows, err := s.q.QueryContext(ctx, `SELECT "user_id"::text, "email" FROM public.account_emailaddress`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying user emails: %w", err)
+	}
+	defer rows.Close()
+
+	emails := make(map[string]string)
+	for rows.Next() {
+		var userID, email string
+		if err := rows.Scan(&userID, &email); err != nil {
+			return nil, fmt.Errorf("error scanning user email: %w", err)
+		}
+		if _, ok := emails[userID]; !ok {
+			emails[userID] = email
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading user emails: %w", err)
+	}
+	return emails, nil
+}
+
+// UserStats returns every row of pickem_api_userstats
+func (s *PostgresStore) UserStats(ctx context.Context) ([]*UserStats, error) {
+	quoted := make([]string, len(userStatsColumns))
+	for i, col := range userStatsColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+	}
+	rows, err := s.q.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM pickem_api_userstats ORDER BY "userID"`, strings.Join(quoted, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("error querying user stats: %w", err)
+	}
+	return scanUserStats(rows)
+}
+
+// UserSeasonStats returns season's pickemcli_userstats_by_season rows. The
+// table's existence is checked first, so a missing table does not abort a
+// read-only transaction.
+func (s *PostgresStore) UserSeasonStats(ctx context.Context, season string) ([]*SeasonStats, error) {
+	var exists bool
+	if err := s.q.QueryRowContext(ctx, `SELECT to_regclass('pickemcli_userstats_by_season') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error looking up season stats: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(seasonStatsColumns))
+	for i, col := range seasonStatsColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+	}
+	rows, err := s.q.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM pickemcli_userstats_by_season WHERE season = $1 ORDER BY "userID"`,
+		strings.Join(quoted, ", ")), season)
+	if err != nil {
+		return nil, fmt.Errorf("error querying season stats: %w", err)
+	}
+	defer rows.Close()
+
+	var all []*SeasonStats
+	for rows.Next() {
+		stats := &SeasonStats{}
+		var email sql.NullString
+		if err := rows.Scan(&stats.UserID, &stats.Season, &email, &stats.WeeksWon, &stats.PickPercent,
+			&stats.CorrectPicks, &stats.TotalPicks, &stats.MostPicked, &stats.LeastPicked,
+			&stats.MissedPicks, &stats.PerfectWeeks); err != nil {
+			return nil, fmt.Errorf("error scanning season stats: %w", err)
+		}
+		stats.UserEmail = email.String
+		all = append(all, stats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading season stats: %w", err)
+	}
+	return all, nil
+}
+
+// UpsertUserStats writes batch with BulkUpsertUserStats, creating the
+// audit table first if needed
+func (s *PostgresStore) UpsertUserStats(ctx context.Context, batch []*UserStats) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+	return BulkUpsertUserStats(ctx, s.db, batch)
+}
+
+// UpsertSeasonStats writes batch with BulkUpsertSeasonStats
+func (s *PostgresStore) UpsertSeasonStats(ctx context.Context, batch []*SeasonStats) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+	return BulkUpsertSeasonStats(ctx, s.db, batch)
+}
+
+// ReplaceLeaderboard writes entries with ReplaceLeaderboard
+func (s *PostgresStore) ReplaceLeaderboard(ctx context.Context, scope string, entries []LeaderboardEntry) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+	return ReplaceLeaderboard(ctx, s.db, scope, entries)
+}
+
+// ReplaceStreaks writes records with ReplaceStreaks
+func (s *PostgresStore) ReplaceStreaks(ctx context.Context, scope string, records []StreakRecord) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+	return ReplaceStreaks(ctx, s.db, scope, records)
+}
+
+// ReplaceTeamAccuracy writes rows with ReplaceTeamAccuracy
+func (s *PostgresStore) ReplaceTeamAccuracy(ctx context.Context, scope string, rows []TeamAccuracy) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+	return ReplaceTeamAccuracy(ctx, s.db, scope, rows)
+}
+
+// ReplaceGameConsensus writes rows with ReplaceGameConsensus
+func (s *PostgresStore) ReplaceGameConsensus(ctx context.Context, season string, rows []GameConsensus) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+	return ReplaceGameConsensus(ctx, s.db, season, rows)
+}
+
+// UpdateWeekWinners writes updates with UpdateWeekWinners
+func (s *PostgresStore) UpdateWeekWinners(ctx context.Context, updates []WeekWinnerUpdate) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return UpdateWeekWinners(ctx, s.db, updates)
+}
+
+// UpdateYearWinners writes updates with UpdateYearWinners
+func (s *PostgresStore) UpdateYearWinners(ctx context.Context, updates []YearWinnerUpdate) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return UpdateYearWinners(ctx, s.db, updates)
+}
+
+// UpdatePickGrades writes grades with UpdatePickGrades
+func (s *PostgresStore) UpdatePickGrades(ctx context.Context, grades []PickGrade) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return UpdatePickGrades(ctx, s.db, grades)
+}
+
+// migrate creates the pickemcli-owned tables the first time one is needed
+func (s *PostgresStore) migrate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.migrated {
+		return nil
+	}
+	if err := Migrate(ctx, s.db); err != nil {
+		return err
+	}
+	s.migrated = true
+	return nil
+}
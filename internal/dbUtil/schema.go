@@ -0,0 +1,335 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ColumnKind is a family of PostgreSQL data types that pickemcli reads the
+// same way
+type ColumnKind string
+
+const (
+	KindText    ColumnKind = "text"
+	KindInteger ColumnKind = "integer"
+	KindBoolean ColumnKind = "boolean"
+	// KindKey columns are identifiers pickemcli only compares and copies,
+	// so any integer, text or uuid type will do
+	KindKey ColumnKind = "key"
+	// KindTextOrInteger columns such as gameseason and gameWeek are read as
+	// strings or numbers and work with either type
+	KindTextOrInteger ColumnKind = "text or integer"
+)
+
+// compatibleTypes maps each kind to the information_schema data_type values
+// that satisfy it
+var compatibleTypes = map[ColumnKind][]string{
+	KindText:          {"text", "character varying", "character"},
+	KindInteger:       {"smallint", "integer", "bigint"},
+	KindBoolean:       {"boolean"},
+	KindKey:           {"smallint", "integer", "bigint", "text", "character varying", "character", "uuid"},
+	KindTextOrInteger: {"smallint", "integer", "bigint", "text", "character varying", "character"},
+}
+
+// RequiredColumn is a Django column pickemcli reads or writes
+type RequiredColumn struct {
+	Table  string
+	Column string
+	Kind   ColumnKind
+}
+
+// RequiredColumns lists every Django table column pickemcli depends on
+var RequiredColumns = func() []RequiredColumn {
+	cols := []RequiredColumn{
+		{"pickem_api_gamepicks", "id", KindKey},
+		{"pickem_api_gamepicks", "uid", KindKey},
+		{"pickem_api_gamepicks", "gameseason", KindTextOrInteger},
+		{"pickem_api_gamepicks", "gameWeek", KindTextOrInteger},
+		{"pickem_api_gamepicks", "pick_game_id", KindKey},
+		{"pickem_api_gamepicks", "pick", KindText},
+		{"pickem_api_gamepicks", "pick_correct", KindBoolean},
+
+		{"pickem_api_gamesandscores", "id", KindKey},
+		{"pickem_api_gamesandscores", "gameseason", KindTextOrInteger},
+		{"pickem_api_gamesandscores", "gameWeek", KindTextOrInteger},
+		{"pickem_api_gamesandscores", "gameScored", KindBoolean},
+		{"pickem_api_gamesandscores", "homeTeam", KindText},
+		{"pickem_api_gamesandscores", "awayTeam", KindText},
+		{"pickem_api_gamesandscores", "homeTeamScore", KindInteger},
+		{"pickem_api_gamesandscores", "awayTeamScore", KindInteger},
+
+		{"pickem_api_userseasonpoints", "userID", KindKey},
+		{"pickem_api_userseasonpoints", "gameseason", KindTextOrInteger},
+	}
+	for week := 1; week <= SeasonWeeks; week++ {
+		cols = append(cols, RequiredColumn{"pickem_api_userseasonpoints", fmt.Sprintf("week_%d_winner", week), KindBoolean})
+	}
+	cols = append(cols, RequiredColumn{"pickem_api_userseasonpoints", "year_winner", KindBoolean})
+
+	for _, col := range userStatsColumns {
+		kind := KindInteger
+		switch col {
+		case "id", "userID":
+			kind = KindKey
+		case "userEmail", "mostPickedSeason", "mostPickedTotal", "leastPickedSeason", "leastPickedTotal":
+			kind = KindText
+		}
+		cols = append(cols, RequiredColumn{"pickem_api_userstats", col, kind})
+	}
+
+	return append(cols,
+		RequiredColumn{"account_emailaddress", "user_id", KindKey},
+		RequiredColumn{"account_emailaddress", "email", KindText},
+	)
+}()
+
+// RequiredUniqueKey is a unique key pickemcli's upserts rely on. INSERT ...
+// ON CONFLICT (Columns) needs a unique index on exactly these columns, and
+// Django does not create one.
+type RequiredUniqueKey struct {
+	Table   string
+	Columns []string
+}
+
+// RequiredUniqueKeys lists every unique key pickemcli's upserts into
+// Django tables rely on
+var RequiredUniqueKeys = []RequiredUniqueKey{
+	{"pickem_api_userstats", []string{"userID"}},
+}
+
+// String returns the key as table ("column", ...)
+func (k RequiredUniqueKey) String() string {
+	quoted := make([]string, len(k.Columns))
+	for i, col := range k.Columns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+	}
+	return fmt.Sprintf("%s (%s)", k.Table, strings.Join(quoted, ", "))
+}
+
+// ColumnStatus is the outcome of checking one required column
+type ColumnStatus string
+
+const (
+	ColumnOK           ColumnStatus = "ok"
+	ColumnMissing      ColumnStatus = "missing"
+	ColumnIncompatible ColumnStatus = "incompatible"
+)
+
+// ColumnCheck is the result of checking one required column
+type ColumnCheck struct {
+	RequiredColumn
+	// DataType is the column's information_schema data_type, empty when
+	// the column is missing
+	DataType string
+	Status   ColumnStatus
+}
+
+// UniqueKeyCheck is the result of checking one required unique key
+type UniqueKeyCheck struct {
+	RequiredUniqueKey
+	Present bool
+}
+
+// SchemaReport is the result of CheckSchema
+type SchemaReport struct {
+	Columns    []ColumnCheck
+	UniqueKeys []UniqueKeyCheck
+}
+
+// Problems returns the checks that did not pass
+func (r *SchemaReport) Problems() []ColumnCheck {
+	var problems []ColumnCheck
+	for _, c := range r.Columns {
+		if c.Status != ColumnOK {
+			problems = append(problems, c)
+		}
+	}
+	return problems
+}
+
+// MissingUniqueKeys returns the required unique keys that do not exist
+func (r *SchemaReport) MissingUniqueKeys() []UniqueKeyCheck {
+	var missing []UniqueKeyCheck
+	for _, k := range r.UniqueKeys {
+		if !k.Present {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
+
+// OK reports whether every required column exists with a compatible type
+// and every required unique key exists
+func (r *SchemaReport) OK() bool {
+	return len(r.Problems()) == 0 && len(r.MissingUniqueKeys()) == 0
+}
+
+// Err returns an error summarising what is wrong with the schema, or nil
+// when it is OK
+func (r *SchemaReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("schema is not compatible: %d column(s) missing or incompatible, %d unique key(s) missing",
+		len(r.Problems()), len(r.MissingUniqueKeys()))
+}
+
+// Write prints the report grouped by table
+func (r *SchemaReport) Write(w io.Writer) {
+	table := ""
+	for _, c := range r.Columns {
+		if c.Table != table {
+			table = c.Table
+			fmt.Fprintf(w, "%s\n", table)
+		}
+		switch c.Status {
+		case ColumnOK:
+			fmt.Fprintf(w, "  ✓ %-24s %s\n", c.Column, c.DataType)
+		case ColumnMissing:
+			fmt.Fprintf(w, "  ✗ %-24s missing (expected %s)\n", c.Column, c.Kind)
+		case ColumnIncompatible:
+			fmt.Fprintf(w, "  ✗ %-24s %s (expected %s)\n", c.Column, c.DataType, c.Kind)
+		}
+	}
+
+	fmt.Fprintf(w, "\nunique keys\n")
+	for _, k := range r.UniqueKeys {
+		if k.Present {
+			fmt.Fprintf(w, "  ✓ %s\n", k)
+		} else {
+			fmt.Fprintf(w, "  ✗ %s missing (upserts fail without it)\n", k)
+		}
+	}
+
+	if problems := r.Problems(); len(problems) > 0 {
+		fmt.Fprintf(w, "\n%d of %d required columns are missing or incompatible\n", len(problems), len(r.Columns))
+	} else {
+		fmt.Fprintf(w, "\nAll %d required columns are present and compatible\n", len(r.Columns))
+	}
+	if missing := r.MissingUniqueKeys(); len(missing) > 0 {
+		fmt.Fprintf(w, "%d of %d required unique keys are missing; remove duplicate rows and add a unique constraint (see README)\n",
+			len(missing), len(r.UniqueKeys))
+	}
+}
+
+// CheckSchema compares RequiredColumns against information_schema and
+// RequiredUniqueKeys against the unique indexes of the current schema
+func CheckSchema(ctx context.Context, db *sql.DB) (*SchemaReport, error) {
+	tableSet := make(map[string]bool)
+	for _, c := range RequiredColumns {
+		tableSet[c.Table] = true
+	}
+	tables := make([]string, 0, len(tableSet))
+	for t := range tableSet {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT table_name, column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ANY($1)`, pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("error reading information_schema: %w", err)
+	}
+	defer rows.Close()
+
+	actual := make(map[string]string)
+	for rows.Next() {
+		var table, column, dataType string
+		if err := rows.Scan(&table, &column, &dataType); err != nil {
+			return nil, fmt.Errorf("error scanning information_schema: %w", err)
+		}
+		actual[table+"."+column] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading information_schema: %w", err)
+	}
+
+	report := &SchemaReport{}
+	for _, required := range RequiredColumns {
+		check := ColumnCheck{RequiredColumn: required, Status: ColumnOK}
+		dataType, ok := actual[required.Table+"."+required.Column]
+		switch {
+		case !ok:
+			check.Status = ColumnMissing
+		case !kindAccepts(required.Kind, dataType):
+			check.DataType = dataType
+			check.Status = ColumnIncompatible
+		default:
+			check.DataType = dataType
+		}
+		report.Columns = append(report.Columns, check)
+	}
+
+	keys, err := uniqueKeys(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, required := range RequiredUniqueKeys {
+		report.UniqueKeys = append(report.UniqueKeys, UniqueKeyCheck{
+			RequiredUniqueKey: required,
+			Present:           keys[required.Table+"."+strings.Join(required.Columns, ",")],
+		})
+	}
+	return report, nil
+}
+
+// uniqueKeys returns the unique indexes of the current schema that ON
+// CONFLICT can infer, as table.column,... (columns in index order). Partial,
+// expression and deferrable indexes cannot be inferred and are left out.
+func uniqueKeys(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.relname, array_agg(a.attname::text ORDER BY k.n)
+		FROM pg_index i
+		JOIN pg_class t ON t.oid = i.indrelid
+		JOIN pg_namespace ns ON ns.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, n)
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE i.indisunique AND i.indimmediate AND i.indpred IS NULL AND i.indexprs IS NULL
+			AND ns.nspname = current_schema()
+		GROUP BY i.indexrelid, t.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading unique indexes: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var table string
+		var columns pq.StringArray
+		if err := rows.Scan(&table, &columns); err != nil {
+			return nil, fmt.Errorf("error scanning unique indexes: %w", err)
+		}
+		keys[table+"."+strings.Join(columns, ",")] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading unique indexes: %w", err)
+	}
+	return keys, nil
+}
+
+// explainConflictTarget adds a pointer to `pickemcli db check` to the error
+// PostgreSQL returns when an ON CONFLICT upsert has no unique key to use
+func explainConflictTarget(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P10" {
+		return fmt.Errorf("%w (pickem_api_userstats needs a unique constraint on \"userID\", see `pickemcli db check`)", err)
+	}
+	return err
+}
+func kindAccepts(kind ColumnKind, dataType string) bool {
+	for _, t := range compatibleTypes[kind] {
+		if strings.EqualFold(t, dataType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// seasonStatsColumns lists the pickemcli_userstats_by_season columns in the
+// same order as the values returned by upsertArgs
+var seasonStatsColumns = []string{
+	"userID", "season", "userEmail", "weeksWon", "pickPercent",
+	"correctPickTotal", "totalPicks", "mostPicked", "leastPicked",
+	"missedPicks", "perfectWeeks",
+}
+
+// upsertSeasonStatsQuery inserts a SeasonStats row, or updates the existing
+// row for the same user and season
+var upsertSeasonStatsQuery = upsertQuery("pickemcli_userstats_by_season", seasonStatsColumns,
+	[]string{"userID", "season"}, []string{"userID", "season"}, "updated_at = now()")
+
+// upsertArgs returns the statement arguments for stats, in seasonStatsColumns order
+func (stats *SeasonStats) upsertArgs() []interface{} {
+	return []interface{}{
+		stats.UserID, stats.Season, stats.UserEmail, stats.WeeksWon, stats.PickPercent,
+		stats.CorrectPicks, stats.TotalPicks, stats.MostPicked, stats.LeastPicked,
+		stats.MissedPicks, stats.PerfectWeeks,
+	}
+}
+
+// BulkUpsertSeasonStats writes a batch of SeasonStats to
+// pickemcli_userstats_by_season in a single transaction. As with
+// BulkUpsertUserStats, nil fields keep their stored values.
+func BulkUpsertSeasonStats(ctx context.Context, db *sql.DB, batch []*SeasonStats) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, upsertSeasonStatsQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing season stats upsert: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, stats := range batch {
+			if _, err := stmt.ExecContext(ctx, stats.upsertArgs()...); err != nil {
+				return fmt.Errorf("error upserting season %s stats for userID %s: %w", stats.Season, stats.UserID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Upserted %d SeasonStats records", len(batch))
+	return nil
+}
@@ -0,0 +1,258 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Snapshot reasons. Automatic snapshots are pruned per reason by
+// PruneSnapshots; manual ones are kept until deleted.
+const (
+	SnapshotManual     = "manual"
+	SnapshotRun        = "run"
+	SnapshotDaemon     = "daemon"
+	SnapshotPreRestore = "pre-restore"
+)
+
+// ErrSnapshotNotFound is returned when restoring a snapshot that does not exist
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot describes a copy of pickem_api_userstats saved in
+// pickemcli_snapshots. The rows themselves are stored as jsonb in
+// pickemcli_snapshot_rows, so columns pickemcli does not know about are
+// kept as well.
+type Snapshot struct {
+	ID        int64
+	CreatedAt time.Time
+	Reason    string
+	RunID     string
+	Rows      int
+}
+
+// CreateSnapshot copies every pickem_api_userstats row into a new snapshot
+// in one transaction. The context's RunID is recorded with it.
+func CreateSnapshot(ctx context.Context, db *sql.DB, reason string) (Snapshot, error) {
+	if err := Migrate(ctx, db); err != nil {
+		return Snapshot{}, err
+	}
+
+	snap := Snapshot{Reason: reason, RunID: RunID(ctx)}
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `INSERT INTO pickemcli_snapshots (reason, run_id) VALUES ($1, NULLIF($2, ''))
+			RETURNING id, created_at`, reason, snap.RunID).Scan(&snap.ID, &snap.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("error creating snapshot: %w", err)
+		}
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO pickemcli_snapshot_rows (snapshot_id, "userID", data)
+			SELECT $1, u."userID", to_jsonb(u) FROM pickem_api_userstats u`, snap.ID)
+		if err != nil {
+			return fmt.Errorf("error copying user stats into snapshot %d: %w", snap.ID, err)
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		snap.Rows = int(rows)
+
+		if _, err := tx.ExecContext(ctx, `UPDATE pickemcli_snapshots SET row_count = $1 WHERE id = $2`, snap.Rows, snap.ID); err != nil {
+			return fmt.Errorf("error creating snapshot: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// ListSnapshots returns every snapshot, newest first
+func ListSnapshots(ctx context.Context, db *sql.DB) ([]Snapshot, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, created_at, reason, COALESCE(run_id, ''), row_count
+		FROM pickemcli_snapshots ORDER BY id DESC`)
+	if isUndefinedTable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snaps []Snapshot
+	for rows.Next() {
+		var snap Snapshot
+		if err := rows.Scan(&snap.ID, &snap.CreatedAt, &snap.Reason, &snap.RunID, &snap.Rows); err != nil {
+			return nil, fmt.Errorf("error scanning snapshot: %w", err)
+		}
+		snaps = append(snaps, snap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading snapshots: %w", err)
+	}
+	return snaps, nil
+}
+
+// restoreSnapshotQuery writes the snapshot's rows back, overwriting (not
+// merging) every column pickemcli manages and recreating deleted rows
+var restoreSnapshotQuery = func() string {
+	cols := make([]string, len(userStatsColumns))
+	selects := make([]string, len(userStatsColumns))
+	var sets []string
+	for i, col := range userStatsColumns {
+		cols[i] = fmt.Sprintf(`"%s"`, col)
+		selects[i] = fmt.Sprintf(`p."%s"`, col)
+		if col != "id" && col != "userID" {
+			sets = append(sets, fmt.Sprintf(`"%[1]s" = EXCLUDED."%[1]s"`, col))
+		}
+	}
+	return fmt.Sprintf(`INSERT INTO pickem_api_userstats (%s)
+		SELECT %s FROM pickemcli_snapshot_rows r, jsonb_populate_record(NULL::pickem_api_userstats, r.data) p
+		WHERE r.snapshot_id = $1
+		ON CONFLICT ("userID") DO UPDATE SET %s`,
+		strings.Join(cols, ", "), strings.Join(selects, ", "), strings.Join(sets, ", "))
+}()
+
+// RestoreSnapshot writes snapshot id back to pickem_api_userstats in one
+// transaction and returns how many users' rows changed. Users created
+// after the snapshot are left as they are. Every changed field is audited
+// under the context's RunID, like an upsert.
+func RestoreSnapshot(ctx context.Context, db *sql.DB, id int64) (int, error) {
+	changed := 0
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pickemcli_snapshots WHERE id = $1)`, id).Scan(&exists)
+		if isUndefinedTable(err) || (err == nil && !exists) {
+			return fmt.Errorf("%w: %d", ErrSnapshotNotFound, id)
+		}
+		if err != nil {
+			return fmt.Errorf("error looking up snapshot %d: %w", id, err)
+		}
+
+		before, err := allUserStats(ctx, tx, true)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, restoreSnapshotQuery, id); err != nil {
+			return fmt.Errorf("error restoring snapshot %d: %w", id, explainConflictTarget(err))
+		}
+		after, err := allUserStats(ctx, tx, false)
+		if err != nil {
+			return err
+		}
+
+		auditStmt, err := tx.PrepareContext(ctx, insertAuditQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing user stats audit: %w", err)
+		}
+		defer auditStmt.Close()
+
+		runID := RunID(ctx)
+		for uid, stats := range after {
+			changes := fieldChanges(before[uid], stats)
+			if len(changes) == 0 {
+				continue
+			}
+			changed++
+			for _, e := range auditEntries(uid, runID, changes) {
+				if _, err := auditStmt.ExecContext(ctx, e.UserID, e.Field, e.Old, e.New, e.RunID); err != nil {
+					return fmt.Errorf("error auditing user stats for userID %s: %w", uid, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// allUserStats returns every pickem_api_userstats row keyed by user ID,
+// optionally locking them until tx ends
+func allUserStats(ctx context.Context, tx *sql.Tx, lock bool) (map[string]*UserStats, error) {
+	quoted := make([]string, len(userStatsColumns))
+	for i, col := range userStatsColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+	}
+	query := fmt.Sprintf(`SELECT %s FROM pickem_api_userstats`, strings.Join(quoted, ", "))
+	if lock {
+		query += " FOR UPDATE"
+	}
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying user stats: %w", err)
+	}
+	all, err := scanUserStats(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[string]*UserStats, len(all))
+	for _, stats := range all {
+		byUser[stats.UserID] = stats
+	}
+	return byUser, nil
+}
+
+// PruneSnapshots deletes all but the newest keep automatic snapshots taken
+// for reason and returns how many were deleted. Each reason is counted
+// separately, so frequent daemon snapshots never push out the ones taken
+// before runs and restores. Manual snapshots are never pruned.
+func PruneSnapshots(ctx context.Context, db *sql.DB, reason string, keep int) (int64, error) {
+	if reason == SnapshotManual {
+		return 0, nil
+	}
+	res, err := db.ExecContext(ctx, `DELETE FROM pickemcli_snapshots WHERE reason = $1 AND id NOT IN (
+			SELECT id FROM pickemcli_snapshots WHERE reason = $1 ORDER BY id DESC LIMIT $2)`,
+		reason, keep)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning snapshots: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// AutoSnapshot takes an automatic snapshot for reason and prunes that
+// reason's snapshots down to the newest keep
+func AutoSnapshot(ctx context.Context, db *sql.DB, reason string, keep int) error {
+	snap, err := CreateSnapshot(ctx, db, reason)
+	if err != nil {
+		return err
+	}
+	log.Printf("Saved snapshot %d of %d UserStats records", snap.ID, snap.Rows)
+	return pruneAndLog(ctx, db, reason, keep)
+}
+
+// RestoreWithSnapshot saves the current rows as a pre-restore snapshot,
+// restores snapshot id and only then prunes the pre-restore snapshots
+// down to the newest keep, so the snapshot being restored (which may
+// itself be a pre-restore one) is never pruned first. It returns how many
+// users' rows changed.
+func RestoreWithSnapshot(ctx context.Context, db *sql.DB, id int64, keep int) (int, error) {
+	snap, err := CreateSnapshot(ctx, db, SnapshotPreRestore)
+	if err != nil {
+		return 0, fmt.Errorf("not restoring, the pre-restore snapshot failed: %w", err)
+	}
+	log.Printf("Saved pre-restore snapshot %d of %d UserStats records", snap.ID, snap.Rows)
+
+	changed, err := RestoreSnapshot(ctx, db, id)
+	if err != nil {
+		return 0, err
+	}
+	return changed, pruneAndLog(ctx, db, SnapshotPreRestore, keep)
+}
+
+func pruneAndLog(ctx context.Context, db *sql.DB, reason string, keep int) error {
+	deleted, err := PruneSnapshots(ctx, db, reason, keep)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Pruned %d old %s snapshot(s), keeping the newest %d", deleted, reason, keep)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package dbUtil
+
+import (
+	"context"
+)
+
+// Store is the set of reads and writes the collectors need against the
+// Django tables. PostgresStore is the production implementation and
+// MemoryStore holds fixture data for running collectors without a database.
+type Store interface {
+	// Picks returns the picks matching filter
+	Picks(ctx context.Context, filter PickFilter) ([]Pick, error)
+
+	// Games returns the games matching filter. Games without a season are
+	// never returned.
+	Games(ctx context.Context, filter GameFilter) ([]Game, error)
+
+	// PickTotals returns every user's total and correct pick counts, all
+	// time and for season, ordered by user ID
+	PickTotals(ctx context.Context, season string) ([]PickTotals, error)
+
+	// GameSeasons returns every distinct season that has games
+	GameSeasons(ctx context.Context) ([]string, error)
+
+	// SeasonPoints returns the season points rows for season, or for every
+	// season when season is empty. Rows without a season are never returned.
+	SeasonPoints(ctx context.Context, season string) ([]SeasonPoints, error)
+
+	// UserEmails returns the email address of every user that has one,
+	// keyed by user ID
+	UserEmails(ctx context.Context) (map[string]string, error)
+
+	// UserStats returns every stored pickem_api_userstats row
+	UserStats(ctx context.Context) ([]*UserStats, error)
+
+	// UserSeasonStats returns season's stored pickemcli_userstats_by_season
+	// rows, ordered by user ID. There are none before the table exists.
+	UserSeasonStats(ctx context.Context, season string) ([]*SeasonStats, error)
+
+	// UpsertUserStats writes a batch of UserStats. Nil fields do not
+	// overwrite stored values.
+	UpsertUserStats(ctx context.Context, batch []*UserStats) error
+
+	// UpsertSeasonStats writes a batch of per-season statistics to the
+	// pickemcli-owned history table. Nil fields do not overwrite stored
+	// values.
+	UpsertSeasonStats(ctx context.Context, batch []*SeasonStats) error
+
+	// ReplaceLeaderboard replaces the stored leaderboard for scope (a
+	// season, or LeaderboardAllTime) with entries
+	ReplaceLeaderboard(ctx context.Context, scope string, entries []LeaderboardEntry) error
+
+	// ReplaceStreaks replaces the stored streaks for scope (a season, or
+	// StreaksAllTime) with records
+	ReplaceStreaks(ctx context.Context, scope string, records []StreakRecord) error
+
+	// ReplaceTeamAccuracy replaces the stored team accuracy matrix for
+	// scope (a season, or TeamAccuracyAllTime) with rows
+	ReplaceTeamAccuracy(ctx context.Context, scope string, rows []TeamAccuracy) error
+
+	// ReplaceGameConsensus replaces the stored consensus of every game in
+	// season with rows
+	ReplaceGameConsensus(ctx context.Context, season string, rows []GameConsensus) error
+
+	// UpdateWeekWinners sets week_N_winner flags on existing season points
+	// rows
+	UpdateWeekWinners(ctx context.Context, updates []WeekWinnerUpdate) error
+
+	// UpdateYearWinners sets year_winner flags on existing season points
+	// rows
+	UpdateYearWinners(ctx context.Context, updates []YearWinnerUpdate) error
+
+	// UpdatePickGrades sets pick_correct on picks
+	UpdatePickGrades(ctx context.Context, grades []PickGrade) error
+}
+
+// PickFilter narrows the picks returned by Store.Picks. Empty fields match
+// everything.
+type PickFilter struct {
+	UID    string
+	Season string
+}
+
+// GameFilter narrows the games returned by Store.Games. Empty fields match
+// everything.
+type GameFilter struct {
+	Season     string
+	ScoredOnly bool
+}
+
+// EmailFor returns the email for userID from emails, or a placeholder
+// when the user has none
+func EmailFor(emails map[string]string, userID string) string {
+	if email, ok := emails[userID]; ok {
+		return email
+	}
+	return PlaceholderEmail(userID)
+}
@@ -0,0 +1,88 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// StreaksAllTime is the StreakRecord.Scope of the all-time streaks
+const StreaksAllTime = "all"
+
+// StreakRecord is one user's streaks, as stored in pickemcli_streaks.
+// Scope is a YYZZ season or StreaksAllTime. Pick streaks count graded
+// picks on scored games; week streaks count weeks with a scored game.
+type StreakRecord struct {
+	Scope     string
+	UserID    string
+	UserEmail string
+	// LongestCorrect and CurrentCorrect are runs of correct picks;
+	// CurrentCorrect ends with the user's latest graded pick
+	LongestCorrect int
+	CurrentCorrect int
+	LongestWrong   int
+	// LongestWeeksWon and CurrentWeeksWon are runs of weeks flagged as
+	// won; CurrentWeeksWon ends with the latest week with a scored game
+	LongestWeeksWon int
+	CurrentWeeksWon int
+	// LongestNoMissed is the longest run of weeks in which the user
+	// picked every scored game
+	LongestNoMissed int
+}
+
+// streakColumns lists the pickemcli_streaks columns in the same order as
+// the values returned by insertArgs
+var streakColumns = []string{
+	"scope", "userID", "userEmail", "longestCorrect", "currentCorrect",
+	"longestWrong", "longestWeeksWon", "currentWeeksWon", "longestNoMissed",
+}
+
+var insertStreakQuery = func() string {
+	quoted := make([]string, len(streakColumns))
+	placeholders := make([]string, len(streakColumns))
+	for i, col := range streakColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf(`INSERT INTO pickemcli_streaks (%s) VALUES (%s)`,
+		strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+}()
+
+// insertArgs returns the statement arguments for r, in streakColumns order
+func (r *StreakRecord) insertArgs() []interface{} {
+	return []interface{}{
+		r.Scope, r.UserID, r.UserEmail, r.LongestCorrect, r.CurrentCorrect,
+		r.LongestWrong, r.LongestWeeksWon, r.CurrentWeeksWon, r.LongestNoMissed,
+	}
+}
+
+// ReplaceStreaks replaces every pickemcli_streaks row of scope with
+// records in a single transaction
+func ReplaceStreaks(ctx context.Context, db *sql.DB, scope string, records []StreakRecord) error {
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pickemcli_streaks WHERE scope = $1`, scope); err != nil {
+			return fmt.Errorf("error clearing %s streaks: %w", scope, err)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, insertStreakQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing streak insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i := range records {
+			if _, err := stmt.ExecContext(ctx, records[i].insertArgs()...); err != nil {
+				return fmt.Errorf("error writing %s streaks for userID %s: %w", scope, records[i].UserID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %d %s streak records", len(records), scope)
+	return nil
+}
@@ -0,0 +1,75 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// TeamAccuracyAllTime is the TeamAccuracy.Scope of the all-time matrix
+const TeamAccuracyAllTime = "all"
+
+// TeamAccuracy is how well one user picks one team, as stored in
+// pickemcli_team_accuracy. Scope is a YYZZ season or TeamAccuracyAllTime.
+// Only graded picks are counted.
+type TeamAccuracy struct {
+	Scope   string
+	UserID  string
+	Team    string
+	Picks   int
+	Correct int
+	// Accuracy is Correct as a whole percentage of Picks
+	Accuracy int
+}
+
+// teamAccuracyColumns lists the pickemcli_team_accuracy columns in the same
+// order as the values returned by insertArgs
+var teamAccuracyColumns = []string{"scope", "userID", "team", "picks", "correct", "accuracy"}
+
+var insertTeamAccuracyQuery = func() string {
+	quoted := make([]string, len(teamAccuracyColumns))
+	placeholders := make([]string, len(teamAccuracyColumns))
+	for i, col := range teamAccuracyColumns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf(`INSERT INTO pickemcli_team_accuracy (%s) VALUES (%s)`,
+		strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+}()
+
+// insertArgs returns the statement arguments for a, in teamAccuracyColumns
+// order
+func (a *TeamAccuracy) insertArgs() []interface{} {
+	return []interface{}{a.Scope, a.UserID, a.Team, a.Picks, a.Correct, a.Accuracy}
+}
+
+// ReplaceTeamAccuracy replaces every pickemcli_team_accuracy row of scope
+// with rows in a single transaction
+func ReplaceTeamAccuracy(ctx context.Context, db *sql.DB, scope string, rows []TeamAccuracy) error {
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pickemcli_team_accuracy WHERE scope = $1`, scope); err != nil {
+			return fmt.Errorf("error clearing %s team accuracy: %w", scope, err)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, insertTeamAccuracyQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing team accuracy insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i := range rows {
+			if _, err := stmt.ExecContext(ctx, rows[i].insertArgs()...); err != nil {
+				return fmt.Errorf("error writing %s team accuracy for userID %s, team %s: %w", scope, rows[i].UserID, rows[i].Team, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %d %s team accuracy rows", len(rows), scope)
+	return nil
+}
@@ -1,202 +1,205 @@
 package dbUtil
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"strings"
 )
 
-// UpsertUserStats performs an upsert operation (INSERT or UPDATE) for UserStats
-// If a record exists for the given userID, it updates only the non-nil fields
-// If no record exists, it creates a new one with the provided data
-func UpsertUserStats(db *sql.DB, stats *UserStats) error {
-	// Check if record exists
-	exists, err := UserStatsExists(db, stats.UserID)
-	if err != nil {
-		return fmt.Errorf("error checking if user stats exists: %w", err)
-	}
-
-	if exists {
-		return UpdateUserStats(db, stats)
-	} else {
-		return InsertUserStats(db, stats)
-	}
-}
-
-// UserStatsExists checks if a UserStats record exists for the given userID
-func UserStatsExists(db *sql.DB, userID string) (bool, error) {
-	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM pickem_api_userstats WHERE "userID" = $1)`
-	err := db.QueryRow(query, userID).Scan(&exists)
-	return exists, err
+// userStatsColumns lists the pickem_api_userstats columns in the same order
+// as the values returned by upsertArgs
+var userStatsColumns = []string{
+	"id", "userEmail", "userID", "weeksWonSeason", "weeksWonTotal",
+	"pickPercentSeason", "pickPercentTotal", "correctPickTotalSeason",
+	"correctPickTotalTotal", "totalPicksSeason", "totalPicksTotal",
+	"mostPickedSeason", "mostPickedTotal", "leastPickedSeason",
+	"leastPickedTotal", "seasonsWon", "missedPicksSeason", "missedPicksTotal",
+	"perfectWeeksSeason", "perfectWeeksTotal",
 }
 
-// GetUserEmail fetches the email for a given user ID from the account_emailaddress table
-// Returns a placeholder email if no record is found
-func GetUserEmail(db *sql.DB, userID string) (string, error) {
-	var email string
-	query := `SELECT "email" FROM public.account_emailaddress WHERE "user_id" = $1`
-	err := db.QueryRow(query, userID).Scan(&email)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// Return a placeholder email for users without email records
-			placeholderEmail := fmt.Sprintf("user-%s@placeholder.local", userID)
-			log.Printf("No email found for userID %s, using placeholder: %s", userID, placeholderEmail)
-			return placeholderEmail, nil
+// upsertUserStatsQuery inserts a UserStats row, or updates the existing row
+// for the same userID
+var upsertUserStatsQuery = upsertQuery("pickem_api_userstats", userStatsColumns,
+	[]string{"userID"}, []string{"id", "userID"})
+
+// upsertQuery builds an INSERT ... ON CONFLICT (conflict) DO UPDATE for
+// table. Columns listed in immutable are never changed by the update. For
+// the rest, NULL in the new row (a nil pointer, or an empty userEmail)
+// keeps the current value rather than overwriting it. extraSets are
+// appended to the SET clause as-is.
+func upsertQuery(table string, columns, conflict, immutable []string, extraSets ...string) string {
+	isImmutable := make(map[string]bool)
+	for _, col := range immutable {
+		isImmutable[col] = true
+	}
+
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	var sets []string
+	for i, col := range columns {
+		quoted[i] = fmt.Sprintf(`"%s"`, col)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+
+		switch {
+		case isImmutable[col]:
+		case col == "userEmail":
+			sets = append(sets, fmt.Sprintf(`"userEmail" = COALESCE(NULLIF(EXCLUDED."userEmail", ''), %s."userEmail")`, table))
+		default:
+			sets = append(sets, fmt.Sprintf(`"%[1]s" = COALESCE(EXCLUDED."%[1]s", %[2]s."%[1]s")`, col, table))
 		}
-		return "", fmt.Errorf("error fetching user email for userID %s: %w", userID, err)
 	}
-	return email, nil
+	sets = append(sets, extraSets...)
+
+	conflictQuoted := make([]string, len(conflict))
+	for i, col := range conflict {
+		conflictQuoted[i] = fmt.Sprintf(`"%s"`, col)
+	}
+
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s`,
+		table, strings.Join(quoted, ", "), strings.Join(placeholders, ", "),
+		strings.Join(conflictQuoted, ", "), strings.Join(sets, ", "))
 }
 
-// InsertUserStats creates a new UserStats record
-func InsertUserStats(db *sql.DB, stats *UserStats) error {
-	query := `
-		INSERT INTO pickem_api_userstats (
-			"id", "userEmail", "userID", "weeksWonSeason", "weeksWonTotal",
-			"pickPercentSeason", "pickPercentTotal", "correctPickTotalSeason",
-			"correctPickTotalTotal", "totalPicksSeason", "totalPicksTotal",
-			"mostPickedSeason", "mostPickedTotal", "leastPickedSeason",
-			"leastPickedTotal", "seasonsWon", "missedPicksSeason", "missedPicksTotal",
-			"perfectWeeksSeason", "perfectWeeksTotal"
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
-	`
-
-	_, err := db.Exec(query,
+// upsertArgs returns the statement arguments for stats, in userStatsColumns order
+func (stats *UserStats) upsertArgs() []interface{} {
+	return []interface{}{
 		stats.ID, stats.UserEmail, stats.UserID, stats.WeeksWonSeason, stats.WeeksWonTotal,
 		stats.PickPercentSeason, stats.PickPercentTotal, stats.CorrectPickTotalSeason,
 		stats.CorrectPickTotalTotal, stats.TotalPicksSeason, stats.TotalPicksTotal,
 		stats.MostPickedSeason, stats.MostPickedTotal, stats.LeastPickedSeason,
 		stats.LeastPickedTotal, stats.SeasonsWon, stats.MissedPicksSeason, stats.MissedPicksTotal,
 		stats.PerfectWeeksSeason, stats.PerfectWeeksTotal,
-	)
+	}
+}
 
+// withTx runs fn in a transaction, committing if it returns nil and
+// rolling back otherwise
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error inserting user stats: %w", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	log.Printf("Inserted new UserStats record for userID: %s", stats.UserID)
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
 	return nil
 }
 
-// UpdateUserStats updates an existing UserStats record with only the non-nil fields
-func UpdateUserStats(db *sql.DB, stats *UserStats) error {
-	setParts := []string{}
-	args := []interface{}{}
-	argIndex := 1
-
-	// Build dynamic UPDATE query based on non-nil fields
-	if stats.UserEmail != "" {
-		setParts = append(setParts, fmt.Sprintf(`"userEmail" = $%d`, argIndex))
-		args = append(args, stats.UserEmail)
-		argIndex++
-	}
-	if stats.WeeksWonSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"weeksWonSeason" = $%d`, argIndex))
-		args = append(args, *stats.WeeksWonSeason)
-		argIndex++
-	}
-	if stats.WeeksWonTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"weeksWonTotal" = $%d`, argIndex))
-		args = append(args, *stats.WeeksWonTotal)
-		argIndex++
-	}
-	if stats.PickPercentSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"pickPercentSeason" = $%d`, argIndex))
-		args = append(args, *stats.PickPercentSeason)
-		argIndex++
-	}
-	if stats.PickPercentTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"pickPercentTotal" = $%d`, argIndex))
-		args = append(args, *stats.PickPercentTotal)
-		argIndex++
-	}
-	if stats.CorrectPickTotalSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"correctPickTotalSeason" = $%d`, argIndex))
-		args = append(args, *stats.CorrectPickTotalSeason)
-		argIndex++
+// scanDest returns pointers to the fields of stats, in userStatsColumns order
+func (stats *UserStats) scanDest() []interface{} {
+	return []interface{}{
+		&stats.ID, &stats.UserEmail, &stats.UserID, &stats.WeeksWonSeason, &stats.WeeksWonTotal,
+		&stats.PickPercentSeason, &stats.PickPercentTotal, &stats.CorrectPickTotalSeason,
+		&stats.CorrectPickTotalTotal, &stats.TotalPicksSeason, &stats.TotalPicksTotal,
+		&stats.MostPickedSeason, &stats.MostPickedTotal, &stats.LeastPickedSeason,
+		&stats.LeastPickedTotal, &stats.SeasonsWon, &stats.MissedPicksSeason, &stats.MissedPicksTotal,
+		&stats.PerfectWeeksSeason, &stats.PerfectWeeksTotal,
 	}
-	if stats.CorrectPickTotalTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"correctPickTotalTotal" = $%d`, argIndex))
-		args = append(args, *stats.CorrectPickTotalTotal)
-		argIndex++
-	}
-	if stats.TotalPicksSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"totalPicksSeason" = $%d`, argIndex))
-		args = append(args, *stats.TotalPicksSeason)
-		argIndex++
-	}
-	if stats.TotalPicksTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"totalPicksTotal" = $%d`, argIndex))
-		args = append(args, *stats.TotalPicksTotal)
-		argIndex++
-	}
-	if stats.MostPickedSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"mostPickedSeason" = $%d`, argIndex))
-		args = append(args, *stats.MostPickedSeason)
-		argIndex++
-	}
-	if stats.MostPickedTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"mostPickedTotal" = $%d`, argIndex))
-		args = append(args, *stats.MostPickedTotal)
-		argIndex++
-	}
-	if stats.LeastPickedSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"leastPickedSeason" = $%d`, argIndex))
-		args = append(args, *stats.LeastPickedSeason)
-		argIndex++
-	}
-	if stats.LeastPickedTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"leastPickedTotal" = $%d`, argIndex))
-		args = append(args, *stats.LeastPickedTotal)
-		argIndex++
-	}
-	if stats.SeasonsWon != nil {
-		setParts = append(setParts, fmt.Sprintf(`"seasonsWon" = $%d`, argIndex))
-		args = append(args, *stats.SeasonsWon)
-		argIndex++
-	}
-	if stats.MissedPicksSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"missedPicksSeason" = $%d`, argIndex))
-		args = append(args, *stats.MissedPicksSeason)
-		argIndex++
-	}
-	if stats.MissedPicksTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"missedPicksTotal" = $%d`, argIndex))
-		args = append(args, *stats.MissedPicksTotal)
-		argIndex++
+}
+
+// scanUserStats reads pickem_api_userstats rows selected in
+// userStatsColumns order and closes rows
+func scanUserStats(rows *sql.Rows) ([]*UserStats, error) {
+	defer rows.Close()
+
+	var all []*UserStats
+	for rows.Next() {
+		stats := &UserStats{}
+		var email sql.NullString
+		dest := stats.scanDest()
+		dest[1] = &email
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning user stats: %w", err)
+		}
+		stats.UserEmail = email.String
+		all = append(all, stats)
 	}
-	if stats.PerfectWeeksSeason != nil {
-		setParts = append(setParts, fmt.Sprintf(`"perfectWeeksSeason" = $%d`, argIndex))
-		args = append(args, *stats.PerfectWeeksSeason)
-		argIndex++
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading user stats: %w", err)
 	}
-	if stats.PerfectWeeksTotal != nil {
-		setParts = append(setParts, fmt.Sprintf(`"perfectWeeksTotal" = $%d`, argIndex))
-		args = append(args, *stats.PerfectWeeksTotal)
-		argIndex++
+	return all, nil
+}
+
+// BulkUpsertUserStats writes a batch of UserStats in a single transaction using
+// INSERT ... ON CONFLICT ("userID") DO UPDATE. New users get a new record;
+// existing users only have their non-nil fields updated. Every field that
+// actually changes is recorded in pickemcli_userstats_audit under the
+// context's RunID. Either the whole batch is written or none of it is.
+func BulkUpsertUserStats(ctx context.Context, db *sql.DB, batch []*UserStats) error {
+	if len(batch) == 0 {
+		return nil
 	}
 
-	if len(setParts) == 0 {
-		return fmt.Errorf("no fields to update")
+	userIDs := make([]string, len(batch))
+	for i, stats := range batch {
+		userIDs[i] = stats.UserID
 	}
+	runID := RunID(ctx)
 
-	// Add userID as the last parameter for WHERE clause
-	args = append(args, stats.UserID)
+	audited := 0
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		current, err := lockUserStats(ctx, tx, userIDs)
+		if err != nil {
+			return err
+		}
 
-	query := fmt.Sprintf(`UPDATE pickem_api_userstats SET %s WHERE "userID" = $%d`,
-		strings.Join(setParts, ", "), argIndex)
+		stmt, err := tx.PrepareContext(ctx, upsertUserStatsQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing user stats upsert: %w", explainConflictTarget(err))
+		}
+		defer stmt.Close()
 
-	_, err := db.Exec(query, args...)
+		auditStmt, err := tx.PrepareContext(ctx, insertAuditQuery)
+		if err != nil {
+			return fmt.Errorf("error preparing user stats audit: %w", err)
+		}
+		defer auditStmt.Close()
+
+		for _, stats := range batch {
+			old := current[stats.UserID]
+			for _, e := range auditEntries(stats.UserID, runID, old.Changes(stats)) {
+				if _, err := auditStmt.ExecContext(ctx, e.UserID, e.Field, e.Old, e.New, e.RunID); err != nil {
+					return fmt.Errorf("error auditing user stats for userID %s: %w", stats.UserID, err)
+				}
+				audited++
+			}
+
+			if _, err := stmt.ExecContext(ctx, stats.upsertArgs()...); err != nil {
+				return fmt.Errorf("error upserting user stats for userID %s: %w", stats.UserID, explainConflictTarget(err))
+			}
+
+			if old == nil {
+				old = &UserStats{UserID: stats.UserID}
+				current[stats.UserID] = old
+			}
+			old.Merge(stats)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("error updating user stats: %w", err)
+		return err
 	}
 
-	log.Printf("Updated UserStats record for userID: %s", stats.UserID)
+	log.Printf("Upserted %d UserStats records (%d field changes audited)", len(batch), audited)
 	return nil
 }
 
+// PlaceholderEmail returns the stand-in email used for users without an
+// account_emailaddress record
+func PlaceholderEmail(userID string) string {
+	placeholderEmail := fmt.Sprintf("user-%s@placeholder.local", userID)
+	log.Printf("No email found for userID %s, using placeholder: %s", userID, placeholderEmail)
+	return placeholderEmail
+}
+
 // Helper functions to create pointers for setting values
 func IntPtr(i int) *int {
 	return &i
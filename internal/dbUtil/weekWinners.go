@@ -0,0 +1,88 @@
+package dbUtil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// WeekWinnerUpdate sets one week_N_winner flag of one
+// pickem_api_userseasonpoints row
+type WeekWinnerUpdate struct {
+	UserID string
+	Season string
+	Week   int
+	Winner bool
+}
+
+// UpdateWeekWinners applies updates to pickem_api_userseasonpoints in a
+// single transaction. Rows that do not exist are not created; an update
+// that matches no row fails the whole batch.
+func UpdateWeekWinners(ctx context.Context, db *sql.DB, updates []WeekWinnerUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		for _, u := range updates {
+			if u.Week < 1 || u.Week > SeasonWeeks {
+				return fmt.Errorf("week %d has no week_N_winner column", u.Week)
+			}
+			if err := setSeasonPointsFlag(ctx, tx, fmt.Sprintf("week_%d_winner", u.Week), u.UserID, u.Season, u.Winner); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Updated %d week winner flags", len(updates))
+	return nil
+}
+
+// YearWinnerUpdate sets the year_winner flag of one
+// pickem_api_userseasonpoints row
+type YearWinnerUpdate struct {
+	UserID string
+	Season string
+	Winner bool
+}
+
+// UpdateYearWinners applies updates to pickem_api_userseasonpoints in a
+// single transaction, failing like UpdateWeekWinners on a missing row
+func UpdateYearWinners(ctx context.Context, db *sql.DB, updates []YearWinnerUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		for _, u := range updates {
+			if err := setSeasonPointsFlag(ctx, tx, "year_winner", u.UserID, u.Season, u.Winner); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Updated %d year winner flags", len(updates))
+	return nil
+}
+
+// setSeasonPointsFlag sets a boolean column of one user's season points row
+func setSeasonPointsFlag(ctx context.Context, tx *sql.Tx, column, userID, season string, value bool) error {
+	res, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE pickem_api_userseasonpoints SET "%s" = $1
+		WHERE "userID"::text = $2 AND gameseason::text = $3`, column), value, userID, season)
+	if err != nil {
+		return fmt.Errorf("error updating %s for userID %s: %w", column, userID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no season %s points row for userID %s", season, userID)
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package daemon
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"log"
 	"time"
 
-	"database/sql"
+	"github.com/google/uuid"
 	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/grade"
 	"github.com/jimdaga/pickemcli/pkg/userStats"
 
 	"github.com/spf13/cobra"
@@ -16,63 +21,147 @@ import (
 var DaemonCmd = &cobra.Command{
 	Use:   "daemon",
 	Short: "Start a daemon process",
-	Long: `Start a daemon process that runs in loop collecting 
+	Long: `Start a daemon process that runs in loop collecting
 			data to populate the family-pickem.com website`,
-	Run: func(cmd *cobra.Command, args []string) {
-		daemon()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if viper.GetBool("dry_run") {
+			return fmt.Errorf("--dry-run is not supported by the daemon; run `pickemcli userStats --dry-run` instead")
+		}
+		return daemon(cmd.Context())
 	},
 }
 
-func collectData(db *sql.DB) {
-	log.Printf("Running User Statistics Collection:")
-	
+// collectData runs one collection cycle. Failures are logged rather than
+// returned so that the daemon keeps running; a lost database connection
+// simply means the cycle is retried on the next tick.
+func collectData(ctx context.Context, database *sql.DB, store dbUtil.Store) {
+	ctx = dbUtil.WithRunID(ctx, uuid.NewString())
+	log.Printf("Running User Statistics Collection (run %s):", dbUtil.RunID(ctx))
+
+	if viper.GetBool("snapshot.auto") {
+		if err := dbUtil.AutoSnapshot(ctx, database, dbUtil.SnapshotDaemon, viper.GetInt("snapshot.daemon_keep")); err != nil {
+			log.Printf("Skipping this cycle, the snapshot failed: %v", err)
+			return
+		}
+	}
+
+	// Grade picks first, so the statistics see this cycle's results
+	if viper.GetBool("grading.daemon") {
+		if err := grade.Current(ctx, store); err != nil {
+			log.Printf("Pick grading failed: %v", err)
+		}
+	}
+
 	// Run all user statistics operations
-	userStats.RunPickStats(db)
+	err := userStats.RunAll(ctx, store)
 	log.Printf("\n")
 
-	userStats.RunTopPicked(db)
-	log.Printf("\n")
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		log.Printf("Data collection cancelled: %v", err)
+	case db.IsConnectionError(err):
+		log.Printf("Lost connection to the database: %v; retrying on the next tick", err)
+	default:
+		log.Printf("Data collection finished with errors: %v", err)
+	}
+}
 
-	userStats.RunLeastPicked(db)
-	log.Printf("\n")
+// pruneHistory deletes the userstats history older than
+// audit.retention_days. Failures are logged and retried next cycle.
+func pruneHistory(ctx context.Context, database *sql.DB) {
+	days := viper.GetInt("audit.retention_days")
+	if days <= 0 {
+		return
+	}
+	deleted, err := dbUtil.PruneHistory(ctx, database, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("Pruning user stats history failed: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Pruned %d user stats history entries older than %d days", deleted, days)
+	}
 }
 
-// Daemon starts the daemon process
-func daemon() {
+// Daemon starts the daemon process and blocks until ctx is cancelled
+// (SIGINT/SIGTERM). The cycle in flight at that point is given
+// daemon.shutdown_grace seconds to finish before it is cancelled.
+func daemon(ctx context.Context) error {
 	log.Printf("Starting daemon\n")
 	log.Printf("\n")
 
 	seconds := viper.GetDuration("daemon.interval") * time.Second
-	ticker := time.NewTicker(seconds)
 	log.Printf("Daemon interval: %v\n", seconds)
 	log.Printf("\n")
 
-	quit := make(chan struct{})
+	grace := viper.GetDuration("daemon.shutdown_grace") * time.Second
+
+	database, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	// Refuse to run against a Django schema we no longer match, rather than
+	// logging a failure for every user on every cycle
+	report, err := dbUtil.CheckSchema(ctx, database)
+	if err != nil {
+		return err
+	}
+	if err := report.Err(); err != nil {
+		report.Write(log.Writer())
+		return fmt.Errorf("%w (see `pickemcli db check`)", err)
+	}
+	log.Printf("Schema check passed (%d columns, %d unique keys)", len(report.Columns), len(report.UniqueKeys))
+
+	store := dbUtil.NewPostgresStore(database)
+
+	ticker := time.NewTicker(seconds)
 
-	db := db.Connect()
-	defer db.Close()
+	// Cycles run on their own context rather than ctx, so that a shutdown
+	// signal lets the current cycle finish instead of aborting it mid-upsert.
+	cycleCtx, cancelCycle := context.WithCancel(context.Background())
+	defer cancelCycle()
 
-	// Run the data collect once before entering the loop:
-	collectData(db)
+	done := make(chan struct{})
 
-	// Run the data collect every N seconds
+	// Run the data collect once, then every N seconds until shutdown
 	go func() {
+		defer close(done)
+
+		collectData(cycleCtx, database, store)
+		pruneHistory(cycleCtx, database)
 		for {
 			select {
 			case <-ticker.C:
-				collectData(db)
-			case <-quit:
-				ticker.Stop()
+				if ctx.Err() != nil {
+					return
+				}
+				collectData(cycleCtx, database, store)
+				pruneHistory(cycleCtx, database)
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
-	// Block forever
-	select {}
-}
 
-func init() {
-	// Set configuration defaults
-	viper.SetDefault("daemon.interval", 30)
-	viper.SetDefault("app.season.current", "2425")
+	// Block until we are asked to stop
+	<-ctx.Done()
+	ticker.Stop()
+	log.Printf("Shutdown requested, waiting up to %v for the current cycle to finish", grace)
+
+	select {
+	case <-done:
+	case <-time.After(grace):
+		log.Printf("Grace period elapsed, cancelling the current cycle")
+		cancelCycle()
+		<-done
+	}
+
+	if err := database.Close(); err != nil {
+		log.Printf("Error closing database connection: %v", err)
+	}
+	log.Printf("Daemon stopped")
+	return nil
 }
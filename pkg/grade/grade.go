@@ -0,0 +1,242 @@
+package grade
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Policies for picks on a game that ends level (a tie or push)
+const (
+	TieIncorrect = "incorrect"
+	TieCorrect   = "correct"
+	// TieUngraded leaves pick_correct NULL
+	TieUngraded = "ungraded"
+)
+
+// CheckTiePolicy returns an error if policy is not a tie policy
+func CheckTiePolicy(policy string) error {
+	switch policy {
+	case TieIncorrect, TieCorrect, TieUngraded:
+		return nil
+	}
+	return fmt.Errorf("unknown tie policy %q (use %s, %s or %s)", policy, TieIncorrect, TieCorrect, TieUngraded)
+}
+
+// Change is a pick whose pick_correct grading changes. Old and New are nil
+// for an ungraded pick.
+type Change struct {
+	Pick   dbUtil.Pick
+	Winner string // empty for a tie
+	Old    *bool
+	New    *bool
+}
+
+// GradeCmd represents the grade command
+var GradeCmd = &cobra.Command{
+	Use:   "grade",
+	Short: "Set pick_correct from the game scores",
+	Long: `Pick Grading
+			Work out the winner of every scored game from pickem_api_gamesandscores
+			and set pick_correct on the picks whose grade changes`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy := viper.GetString("grading.tie")
+		if err := CheckTiePolicy(policy); err != nil {
+			return fmt.Errorf("grading.tie: %w", err)
+		}
+		seasonCode, _ := cmd.Flags().GetString("season")
+		week, _ := cmd.Flags().GetInt("week")
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		var store dbUtil.Store = dbUtil.NewPostgresStore(database)
+		if seasonCode == "" {
+			current, err := season.Current(ctx, store)
+			if err != nil {
+				return err
+			}
+			seasonCode = current.String()
+		} else {
+			s, err := season.Parse(seasonCode)
+			if err != nil {
+				return fmt.Errorf("--season: %w", err)
+			}
+			seasonCode = s.String()
+		}
+
+		changes, err := Grade(ctx, store, seasonCode, week, policy)
+		if err != nil {
+			return err
+		}
+		if err := output.Write(cmd.OutOrStdout(), output.Format(), changesTable(changes)); err != nil {
+			return err
+		}
+
+		if viper.GetBool("dry_run") {
+			log.Printf("Dry run: %d pick grade(s) would change", len(changes))
+			return nil
+		}
+		return Apply(ctx, store, changes)
+	},
+}
+
+func init() {
+	GradeCmd.Flags().String("season", "", "Season to grade (YYZZ) (default: the current season)")
+	GradeCmd.Flags().Int("week", 0, "Only grade this week (default: every week)")
+	config.RegisterCheck("grading.tie", CheckTiePolicy)
+}
+
+// Grade works out the grade of every pick on a scored game in seasonCode
+// (and week, unless 0) and returns the picks whose pick_correct would
+// change. Picks on games without both scores, or on a team that is
+// neither the home nor the away team, are skipped and logged.
+func Grade(ctx context.Context, store dbUtil.Store, seasonCode string, week int, policy string) ([]Change, error) {
+	if err := CheckTiePolicy(policy); err != nil {
+		return nil, err
+	}
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonCode, ScoredOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error getting scored games: %w", err)
+	}
+
+	changes, skipped := grade(picks, games, week, policy)
+	if skipped > 0 {
+		log.Printf("Skipped %d pick(s) on games without both scores or on neither team", skipped)
+	}
+	return changes, nil
+}
+
+// grade does the work of Grade and also returns how many picks on scored
+// games could not be graded
+func grade(picks []dbUtil.Pick, scoredGames []dbUtil.Game, week int, policy string) ([]Change, int) {
+	gamesByID := make(map[string]dbUtil.Game, len(scoredGames))
+	for _, g := range scoredGames {
+		gamesByID[g.ID] = g
+	}
+
+	var changes []Change
+	skipped := 0
+	for _, p := range picks {
+		if week != 0 && p.Week != week {
+			continue
+		}
+		g, ok := gamesByID[p.GameID]
+		if !ok {
+			continue
+		}
+		if g.HomeScore == nil || g.AwayScore == nil {
+			skipped++
+			continue
+		}
+
+		var winner string
+		switch {
+		case *g.HomeScore > *g.AwayScore:
+			winner = g.HomeTeam
+		case *g.AwayScore > *g.HomeScore:
+			winner = g.AwayTeam
+		}
+
+		team := strings.TrimSpace(p.Team)
+		if !dbUtil.SameTeam(team, g.HomeTeam) && !dbUtil.SameTeam(team, g.AwayTeam) {
+			skipped++
+			continue
+		}
+
+		var graded *bool
+		switch {
+		case winner != "":
+			graded = boolPtr(dbUtil.SameTeam(team, winner))
+		case policy == TieCorrect:
+			graded = boolPtr(true)
+		case policy == TieIncorrect:
+			graded = boolPtr(false)
+		}
+
+		var old *bool
+		if p.Graded {
+			old = boolPtr(p.Correct)
+		}
+		if equalGrade(old, graded) {
+			continue
+		}
+		changes = append(changes, Change{Pick: p, Winner: winner, Old: old, New: graded})
+	}
+	return changes, skipped
+}
+
+// Apply writes the new grade of every change
+func Apply(ctx context.Context, store dbUtil.Store, changes []Change) error {
+	grades := make([]dbUtil.PickGrade, len(changes))
+	for i, c := range changes {
+		grades[i] = dbUtil.PickGrade{PickID: c.Pick.ID, Correct: c.New}
+	}
+	return store.UpdatePickGrades(ctx, grades)
+}
+
+// Current grades the current season and writes the changes, as the daemon
+// does before each collection cycle
+func Current(ctx context.Context, store dbUtil.Store) error {
+	policy := viper.GetString("grading.tie")
+	current, err := season.Current(ctx, store)
+	if err != nil {
+		return err
+	}
+	changes, err := Grade(ctx, store, current.String(), 0, policy)
+	if err != nil {
+		return err
+	}
+	return Apply(ctx, store, changes)
+}
+
+func equalGrade(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// changesTable lays out changes for output.Write
+func changesTable(changes []Change) output.Table {
+	t := output.Table{Columns: []string{"season", "week", "pickID", "userID", "gameID", "pick", "winner", "old", "new"}}
+	for _, c := range changes {
+		var winner, old, updated interface{}
+		if c.Winner != "" {
+			winner = c.Winner
+		}
+		if c.Old != nil {
+			old = *c.Old
+		}
+		if c.New != nil {
+			updated = *c.New
+		}
+		t.Append(c.Pick.Season, c.Pick.Week, c.Pick.ID, c.Pick.UID, c.Pick.GameID, c.Pick.Team, winner, old, updated)
+	}
+	return t
+}
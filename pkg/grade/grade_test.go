@@ -0,0 +1,185 @@
+package grade
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+func score(n int) *int {
+	return &n
+}
+
+// gradeResult is a Change reduced to what the tests compare
+type gradeResult struct {
+	PickID string
+	Winner string
+	Old    *bool
+	New    *bool
+}
+
+func TestGrade(t *testing.T) {
+	homeWin := dbUtil.Game{ID: "1", Week: 1, HomeTeam: "Jets", AwayTeam: "Bills", HomeScore: score(24), AwayScore: score(17)}
+	awayWin := dbUtil.Game{ID: "2", Week: 1, HomeTeam: "Jets", AwayTeam: "Bills", HomeScore: score(10), AwayScore: score(17)}
+	tie := dbUtil.Game{ID: "3", Week: 2, HomeTeam: "Jets", AwayTeam: "Bills", HomeScore: score(20), AwayScore: score(20)}
+	unscored := dbUtil.Game{ID: "4", Week: 2, HomeTeam: "Jets", AwayTeam: "Bills", HomeScore: score(20)}
+
+	tests := []struct {
+		name    string
+		picks   []dbUtil.Pick
+		games   []dbUtil.Game
+		week    int
+		policy  string
+		want    []gradeResult
+		skipped int
+	}{
+		{
+			name: "home win",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "1", Week: 1, Team: "Jets"},
+				{ID: "b", GameID: "1", Week: 1, Team: "Bills"},
+			},
+			games:  []dbUtil.Game{homeWin},
+			policy: TieIncorrect,
+			want: []gradeResult{
+				{PickID: "a", Winner: "Jets", New: boolPtr(true)},
+				{PickID: "b", Winner: "Jets", New: boolPtr(false)},
+			},
+		},
+		{
+			name: "away win",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "2", Week: 1, Team: "Jets"},
+				{ID: "b", GameID: "2", Week: 1, Team: "Bills"},
+			},
+			games:  []dbUtil.Game{awayWin},
+			policy: TieIncorrect,
+			want: []gradeResult{
+				{PickID: "a", Winner: "Bills", New: boolPtr(false)},
+				{PickID: "b", Winner: "Bills", New: boolPtr(true)},
+			},
+		},
+		{
+			name:   "tie incorrect",
+			picks:  []dbUtil.Pick{{ID: "a", GameID: "3", Week: 2, Team: "Jets"}},
+			games:  []dbUtil.Game{tie},
+			policy: TieIncorrect,
+			want:   []gradeResult{{PickID: "a", New: boolPtr(false)}},
+		},
+		{
+			name:   "tie correct",
+			picks:  []dbUtil.Pick{{ID: "a", GameID: "3", Week: 2, Team: "Bills"}},
+			games:  []dbUtil.Game{tie},
+			policy: TieCorrect,
+			want:   []gradeResult{{PickID: "a", New: boolPtr(true)}},
+		},
+		{
+			name: "tie ungraded clears a grade",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "3", Week: 2, Team: "Jets", Correct: true, Graded: true},
+				{ID: "b", GameID: "3", Week: 2, Team: "Bills"},
+			},
+			games:  []dbUtil.Game{tie},
+			policy: TieUngraded,
+			want:   []gradeResult{{PickID: "a", Old: boolPtr(true)}},
+		},
+		{
+			name: "unscored games",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "4", Week: 2, Team: "Jets"},
+				{ID: "b", GameID: "5", Week: 2, Team: "Jets"},
+			},
+			games:   []dbUtil.Game{unscored},
+			policy:  TieIncorrect,
+			skipped: 1,
+		},
+		{
+			name: "pick on neither team",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "1", Week: 1, Team: "Dolphins"},
+				{ID: "b", GameID: "1", Week: 1, Team: " "},
+			},
+			games:   []dbUtil.Game{homeWin},
+			policy:  TieIncorrect,
+			skipped: 2,
+		},
+		{
+			name: "case and whitespace",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "1", Week: 1, Team: " jets"},
+				{ID: "b", GameID: "1", Week: 1, Team: "BILLS "},
+			},
+			games:  []dbUtil.Game{homeWin},
+			policy: TieIncorrect,
+			want: []gradeResult{
+				{PickID: "a", Winner: "Jets", New: boolPtr(true)},
+				{PickID: "b", Winner: "Jets", New: boolPtr(false)},
+			},
+		},
+		{
+			name: "week filter",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "1", Week: 1, Team: "Jets"},
+				{ID: "b", GameID: "3", Week: 2, Team: "Jets"},
+			},
+			games:  []dbUtil.Game{homeWin, tie},
+			week:   2,
+			policy: TieCorrect,
+			want:   []gradeResult{{PickID: "b", New: boolPtr(true)}},
+		},
+		{
+			name: "unchanged grades are left out",
+			picks: []dbUtil.Pick{
+				{ID: "a", GameID: "1", Week: 1, Team: "Jets", Correct: true, Graded: true},
+				{ID: "b", GameID: "1", Week: 1, Team: "Bills", Graded: true},
+				{ID: "c", GameID: "1", Week: 1, Team: "Bills", Correct: true, Graded: true},
+				{ID: "d", GameID: "3", Week: 2, Team: "Bills"},
+			},
+			games:  []dbUtil.Game{homeWin, tie},
+			policy: TieUngraded,
+			want:   []gradeResult{{PickID: "c", Winner: "Jets", Old: boolPtr(true), New: boolPtr(false)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, skipped := grade(tt.picks, tt.games, tt.week, tt.policy)
+			var got []gradeResult
+			for _, c := range changes {
+				got = append(got, gradeResult{PickID: c.Pick.ID, Winner: c.Winner, Old: c.Old, New: c.New})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes\n got: %s\nwant: %s", describe(got), describe(tt.want))
+			}
+			if skipped != tt.skipped {
+				t.Errorf("skipped = %d, want %d", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestGradeRejectsUnknownPolicy(t *testing.T) {
+	if _, err := Grade(context.Background(), dbUtil.NewMemoryStore(), "2425", 0, "push"); err == nil {
+		t.Error("Grade accepted tie policy \"push\"")
+	}
+}
+
+// describe renders results with their grades dereferenced
+func describe(results []gradeResult) []string {
+	grade := func(b *bool) string {
+		if b == nil {
+			return "nil"
+		}
+		if *b {
+			return "true"
+		}
+		return "false"
+	}
+	var out []string
+	for _, r := range results {
+		out = append(out, r.PickID+" "+r.Winner+" "+grade(r.Old)+"->"+grade(r.New))
+	}
+	return out
+}
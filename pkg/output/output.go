@@ -0,0 +1,177 @@
+// Package output renders command results for people (an aligned table) and
+// for scripts (JSON, NDJSON or CSV). Results always go to the writer given,
+// normally stdout, while logs stay on stderr.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+)
+
+// Formats accepted by --output
+const (
+	FormatTable  = "table"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// Formats lists every supported format
+var Formats = []string{FormatTable, FormatJSON, FormatNDJSON, FormatCSV}
+
+// Table is a set of rows with named columns. Values are written as they
+// are; nil is an empty cell in a table or CSV and null in JSON.
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Append adds a row, which must have one value per column
+func (t *Table) Append(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+func init() {
+	config.RegisterCheck("output", Check)
+}
+
+// Check returns an error if format is not one of Formats
+func Check(format string) error {
+	for _, f := range Formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q (use table, json, ndjson or csv)", format)
+}
+
+// Format returns the format chosen with --output
+func Format() string {
+	return viper.GetString("output")
+}
+
+// Write renders t to w in format
+func Write(w io.Writer, format string, t Table) error {
+	switch format {
+	case FormatTable:
+		return writeTable(w, t)
+	case FormatJSON:
+		return writeJSON(w, t)
+	case FormatNDJSON:
+		return writeNDJSON(w, t)
+	case FormatCSV:
+		return writeCSV(w, t)
+	}
+	return Check(format)
+}
+
+func writeTable(w io.Writer, t Table) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, col := range t.Columns {
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, col)
+	}
+	fmt.Fprintln(tw)
+	for _, row := range t.Rows {
+		for i, v := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, cell(v))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, t Table) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, row := range t.Rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := writeObject(&buf, t.Columns, row); err != nil {
+			return err
+		}
+	}
+	if len(t.Rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeNDJSON(w io.Writer, t Table) error {
+	var buf bytes.Buffer
+	for _, row := range t.Rows {
+		if err := writeObject(&buf, t.Columns, row); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeObject writes row as a JSON object, keeping the column order
+// (encoding a map would sort the keys)
+func writeObject(buf *bytes.Buffer, columns []string, row []interface{}) error {
+	buf.WriteString("{")
+	for i, col := range columns {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(row[i])
+		if err != nil {
+			return fmt.Errorf("error encoding %s: %w", col, err)
+		}
+		buf.Write(key)
+		buf.WriteString(": ")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+func writeCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			record[i] = cell(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// cell formats v for a table or CSV cell
+func cell(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
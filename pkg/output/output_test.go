@@ -0,0 +1,111 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// sample has columns out of alphabetical order, a nil cell and values
+// that need CSV quoting
+func sample() Table {
+	t := Table{Columns: []string{"userID", "email", "picks", "won"}}
+	t.Append("2", "two@example.com", 10, true)
+	t.Append("1", nil, 0, false)
+	t.Append("3", `"Big" Al, Jr.`, 3, nil)
+	return t
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format string
+		table  Table
+		want   string
+	}{
+		{
+			format: FormatJSON,
+			table:  sample(),
+			want: `[
+  {"userID": "2", "email": "two@example.com", "picks": 10, "won": true},
+  {"userID": "1", "email": null, "picks": 0, "won": false},
+  {"userID": "3", "email": "\"Big\" Al, Jr.", "picks": 3, "won": null}
+]
+`,
+		},
+		{
+			format: FormatJSON,
+			table:  Table{Columns: []string{"userID"}},
+			want:   "[]\n",
+		},
+		{
+			format: FormatNDJSON,
+			table:  sample(),
+			want: `{"userID": "2", "email": "two@example.com", "picks": 10, "won": true}
+{"userID": "1", "email": null, "picks": 0, "won": false}
+{"userID": "3", "email": "\"Big\" Al, Jr.", "picks": 3, "won": null}
+`,
+		},
+		{
+			format: FormatNDJSON,
+			table:  Table{Columns: []string{"userID"}},
+			want:   "",
+		},
+		{
+			format: FormatCSV,
+			table:  sample(),
+			want: `userID,email,picks,won
+2,two@example.com,10,true
+1,,0,false
+3,"""Big"" Al, Jr.",3,
+`,
+		},
+		{
+			format: FormatCSV,
+			table:  Table{Columns: []string{"userID", "email"}},
+			want:   "userID,email\n",
+		},
+		{
+			format: FormatTable,
+			table:  sample(),
+			// The empty last cell still pads its row
+			want: "userID  email            picks  won\n" +
+				"2       two@example.com  10     true\n" +
+				"1                        0      false\n" +
+				"3       \"Big\" Al, Jr.    3      \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, tt.table); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Write(%s)\n got: %q\nwant: %q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, format := range Formats {
+		if err := Check(format); err != nil {
+			t.Errorf("Check(%q) = %v", format, err)
+		}
+	}
+	for _, format := range []string{"", "yaml", "JSON"} {
+		if err := Check(format); err == nil {
+			t.Errorf("Check(%q) accepted an unknown format", format)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, "yaml", sample())
+	if err == nil || !strings.Contains(err.Error(), `unknown output format "yaml"`) {
+		t.Errorf("Write(yaml) error = %v, want unknown output format", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Write(yaml) wrote %q", buf.String())
+	}
+}
@@ -0,0 +1,397 @@
+package report
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	texttemplate "text/template"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/jimdaga/pickemcli/pkg/userStats"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Report formats
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// CheckFormat returns an error if format is not a report format
+func CheckFormat(format string) error {
+	switch format {
+	case FormatMarkdown, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("unknown report format %q (use %s or %s)", format, FormatMarkdown, FormatHTML)
+}
+
+// CheckUpsets returns an error if limit, the number of upset calls a
+// report lists, is negative
+func CheckUpsets(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("must not be negative, got %d", limit)
+	}
+	return nil
+}
+
+// templates holds the built-in templates, used unless report.template_dir
+// or --template provides one
+//
+//go:embed templates
+var templates embed.FS
+
+// templateName returns the file name of the week template for format
+func templateName(format string) string {
+	if format == FormatHTML {
+		return "week.html.tmpl"
+	}
+	return "week.md.tmpl"
+}
+
+// Score is one user's results in the reported week. Picks and Correct
+// count games, so a duplicate pick on a game counts once.
+type Score struct {
+	UserID    string
+	UserEmail string
+	Correct   int
+	Picks     int
+	// Missed counts the week's games the user has no pick for
+	Missed int
+}
+
+// Upset is a correct pick few of the league made
+type Upset struct {
+	UserID    string
+	UserEmail string
+	GameID    string
+	Team      string
+	Opponent  string
+	// Share is the whole percentage of the game's picks that took Team
+	Share int
+}
+
+// WeekReport is everything a week template can show
+type WeekReport struct {
+	Season string
+	Week   int
+	Games  int
+	// Winners are the week's winners under week_winners.tie_break
+	Winners []Score
+	// Scores lists every user with a pick this week, most correct first
+	Scores  []Score
+	Highest []Score
+	Lowest  []Score
+	Perfect []Score
+	// Upsets are the week's upset calls, rarest first, at most
+	// report.upsets of them
+	Upsets []Upset
+	// Missed lists the users with a pick this season who missed a game
+	// this week
+	Missed []Score
+	// Standings is the season leaderboard after the week, with movement
+	// since the week before
+	Standings []dbUtil.LeaderboardEntry
+}
+
+// ReportCmd groups the report commands
+var ReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate recaps",
+	Long: `Recap Reports
+			Render recaps as Markdown or HTML from templates that can be replaced
+			without rebuilding`,
+}
+
+var weekCmd = &cobra.Command{
+	Use:   "week",
+	Short: "Generate the recap of a fully scored week",
+	Long: `Weekly Recap
+			Render the winners, perfect weeks, highest and lowest scores, biggest
+			upset calls, missed picks and standings movement of a week`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := viper.GetString("report.format")
+		if err := CheckFormat(format); err != nil {
+			return fmt.Errorf("report.format: %w", err)
+		}
+		policy := viper.GetString("week_winners.tie_break")
+		if err := userStats.CheckTieBreak(policy); err != nil {
+			return fmt.Errorf("week_winners.tie_break: %w", err)
+		}
+		if err := CheckUpsets(viper.GetInt("report.upsets")); err != nil {
+			return fmt.Errorf("report.upsets: %w", err)
+		}
+		seasonCode, _ := cmd.Flags().GetString("season")
+		week, _ := cmd.Flags().GetInt("week")
+		if week < 1 || week > dbUtil.SeasonWeeks {
+			return fmt.Errorf("--week: must be between 1 and %d, got %d", dbUtil.SeasonWeeks, week)
+		}
+		path, _ := cmd.Flags().GetString("template")
+		tmpl, err := loadTemplate(format, path)
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		var store dbUtil.Store = dbUtil.NewPostgresStore(database)
+		if seasonCode == "" {
+			current, err := season.Current(ctx, store)
+			if err != nil {
+				return err
+			}
+			seasonCode = current.String()
+		} else {
+			s, err := season.Parse(seasonCode)
+			if err != nil {
+				return fmt.Errorf("--season: %w", err)
+			}
+			seasonCode = s.String()
+		}
+
+		report, err := Week(ctx, store, seasonCode, week, policy)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(cmd.OutOrStdout(), report)
+	},
+}
+
+func init() {
+	weekCmd.Flags().String("season", "", "Season of the week (YYZZ) (default: the current season)")
+	weekCmd.Flags().Int("week", 0, "Week to recap")
+	weekCmd.Flags().String("format", "", "Report format: markdown or html (default: report.format)")
+	weekCmd.Flags().String("template", "", "Template file to render instead of the built-in or report.template_dir one")
+	if err := weekCmd.MarkFlagRequired("week"); err != nil {
+		panic(err.Error())
+	}
+	if err := viper.BindPFlag("report.format", weekCmd.Flags().Lookup("format")); err != nil {
+		panic(err.Error())
+	}
+	ReportCmd.AddCommand(weekCmd)
+	config.RegisterCheck("report.format", CheckFormat)
+	config.RegisterIntCheck("report.upsets", CheckUpsets)
+}
+
+// executor is the part of text/template and html/template templates the
+// command needs
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// loadTemplate parses the week template for format: the file at path if
+// given, else the one in report.template_dir if it has one, else the
+// built-in one. HTML templates are parsed with html/template, so values
+// are escaped.
+func loadTemplate(format, path string) (executor, error) {
+	name := templateName(format)
+	var src []byte
+	var err error
+	switch {
+	case path != "":
+		src, err = os.ReadFile(path)
+	case viper.GetString("report.template_dir") != "":
+		src, err = os.ReadFile(filepath.Join(viper.GetString("report.template_dir"), name))
+		if os.IsNotExist(err) {
+			src, err = templates.ReadFile("templates/" + name)
+		}
+	default:
+		src, err = templates.ReadFile("templates/" + name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s template: %w", format, err)
+	}
+
+	if format == FormatHTML {
+		tmpl, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(funcs)).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s template: %w", format, err)
+		}
+		return tmpl, nil
+	}
+	tmpl, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(funcs)).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s template: %w", format, err)
+	}
+	return tmpl, nil
+}
+
+// funcs are the functions available to templates
+var funcs = map[string]interface{}{
+	// movement formats a leaderboard movement as +N, -N or 0, and an
+	// empty movement as "-"
+	"movement": func(m *int) string {
+		switch {
+		case m == nil:
+			return "-"
+		case *m > 0:
+			return fmt.Sprintf("+%d", *m)
+		}
+		return fmt.Sprint(*m)
+	},
+}
+
+// Week gathers the recap of week in seasonCode, which must be fully
+// scored, breaking week winner ties by policy
+func Week(ctx context.Context, store dbUtil.Store, seasonCode string, week int, policy string) (*WeekReport, error) {
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting games: %w", err)
+	}
+	weekGames := make(map[string]dbUtil.Game)
+	var scored int
+	for _, g := range games {
+		if g.Week != week {
+			continue
+		}
+		weekGames[g.ID] = g
+		if g.Scored {
+			scored++
+		}
+	}
+	if len(weekGames) == 0 {
+		return nil, fmt.Errorf("week %d of season %s has no games", week, seasonCode)
+	}
+	if scored < len(weekGames) {
+		return nil, fmt.Errorf("week %d of season %s is not fully scored: %d of %d games scored", week, seasonCode, scored, len(weekGames))
+	}
+
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
+	}
+
+	r := &WeekReport{Season: seasonCode, Week: week, Games: len(weekGames)}
+
+	// Every user with a pick this season, and the games they picked this
+	// week. A game picked more than once counts once, as correct if any of
+	// its picks is.
+	scores := make(map[string]*Score)
+	picked := make(map[string]map[string]bool)
+	for _, p := range picks {
+		if _, ok := scores[p.UID]; !ok {
+			scores[p.UID] = &Score{UserID: p.UID, UserEmail: dbUtil.EmailFor(emails, p.UID)}
+			picked[p.UID] = make(map[string]bool)
+		}
+		if _, ok := weekGames[p.GameID]; !ok || p.Week != week {
+			continue
+		}
+		picked[p.UID][p.GameID] = picked[p.UID][p.GameID] || p.Correct
+	}
+	all := make([]Score, 0, len(scores))
+	for uid, s := range scores {
+		for _, correct := range picked[uid] {
+			s.Picks++
+			if correct {
+				s.Correct++
+			}
+		}
+		s.Missed = r.Games - s.Picks
+		all = append(all, *s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Correct != all[j].Correct {
+			return all[i].Correct > all[j].Correct
+		}
+		return all[i].UserID < all[j].UserID
+	})
+	for _, s := range all {
+		if s.Missed > 0 {
+			r.Missed = append(r.Missed, s)
+		}
+		if s.Picks == 0 {
+			continue
+		}
+		r.Scores = append(r.Scores, s)
+		if s.Picks == r.Games && s.Correct == r.Games {
+			r.Perfect = append(r.Perfect, s)
+		}
+	}
+	if len(r.Scores) > 0 {
+		high, low := r.Scores[0].Correct, r.Scores[len(r.Scores)-1].Correct
+		for _, s := range r.Scores {
+			if s.Correct == high {
+				r.Highest = append(r.Highest, s)
+			}
+			if s.Correct == low {
+				r.Lowest = append(r.Lowest, s)
+			}
+		}
+	}
+
+	// Winners
+	checks, err := userStats.CheckWeekWinners(ctx, store, seasonCode, policy)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range checks {
+		if c.Week == week && c.Computed {
+			if s, ok := scores[c.UserID]; ok {
+				r.Winners = append(r.Winners, *s)
+			}
+		}
+	}
+
+	// Biggest upset calls
+	consensus, err := userStats.Consensus(ctx, store, seasonCode, viper.GetInt("consensus.upset_threshold"))
+	if err != nil {
+		return nil, err
+	}
+	var upsets []userStats.UpsetCall
+	for _, u := range consensus.Upsets {
+		if u.Week == week {
+			upsets = append(upsets, u)
+		}
+	}
+	sort.SliceStable(upsets, func(i, j int) bool { return upsets[i].Share < upsets[j].Share })
+	limit := viper.GetInt("report.upsets")
+	if err := CheckUpsets(limit); err != nil {
+		return nil, fmt.Errorf("report.upsets: %w", err)
+	}
+	if len(upsets) > limit {
+		upsets = upsets[:limit]
+	}
+	for _, u := range upsets {
+		opponent := weekGames[u.GameID].HomeTeam
+		if dbUtil.SameTeam(u.Team, opponent) {
+			opponent = weekGames[u.GameID].AwayTeam
+		}
+		r.Upsets = append(r.Upsets, Upset{
+			UserID:    u.UserID,
+			UserEmail: dbUtil.EmailFor(emails, u.UserID),
+			GameID:    u.GameID,
+			Team:      u.Team,
+			Opponent:  opponent,
+			Share:     u.Share,
+		})
+	}
+
+	// Standings after the week
+	opts := userStats.LeaderboardOptions{Scope: seasonCode, RankBy: viper.GetString("leaderboard.rank_by"), Through: week}
+	if opts.TieBreakers, err = userStats.ParseTieBreakers(viper.GetString("leaderboard.tie_breakers")); err != nil {
+		return nil, fmt.Errorf("leaderboard.tie_breakers: %w", err)
+	}
+	if r.Standings, err = userStats.Leaderboard(ctx, store, opts); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
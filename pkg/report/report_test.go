@@ -0,0 +1,266 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/spf13/viper"
+)
+
+// weekStore returns a MemoryStore for season 2425. In week 1 the home
+// team won games 1 and 3 and the away team game 2; in week 2 the away
+// team won both games. Week 3 is not fully scored.
+//
+// Week 1: user 1 is perfect, user 2 picked game 1 twice, user 3 missed
+// game 3, user 4 did not pick at all and user 5 got nothing right.
+func weekStore() *dbUtil.MemoryStore {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2425", Week: 1, Scored: true, HomeTeam: "Jets", AwayTeam: "Bills"},
+		{ID: "2", Season: "2425", Week: 1, Scored: true, HomeTeam: "Dolphins", AwayTeam: "Patriots"},
+		{ID: "3", Season: "2425", Week: 1, Scored: true, HomeTeam: "Giants", AwayTeam: "Eagles"},
+		{ID: "4", Season: "2425", Week: 2, Scored: true, HomeTeam: "Jets", AwayTeam: "Bills"},
+		{ID: "5", Season: "2425", Week: 2, Scored: true, HomeTeam: "Giants", AwayTeam: "Eagles"},
+		{ID: "6", Season: "2425", Week: 3, Scored: true, HomeTeam: "Jets", AwayTeam: "Dolphins"},
+		{ID: "7", Season: "2425", Week: 3, HomeTeam: "Bills", AwayTeam: "Patriots"},
+	}
+	pick := func(uid, gameID string, week int, team string, correct bool) dbUtil.Pick {
+		return dbUtil.Pick{UID: uid, Season: "2425", Week: week, GameID: gameID, Team: team, Correct: correct, Graded: true}
+	}
+	store.PickRows = []dbUtil.Pick{
+		pick("1", "1", 1, "Jets", true), pick("1", "2", 1, "patriots", true), pick("1", "3", 1, "Giants", true),
+		pick("2", "1", 1, "Jets", true), pick("2", "1", 1, "Jets", true),
+		pick("2", "2", 1, "Dolphins", false), pick("2", "3", 1, "Eagles", false),
+		pick("3", "1", 1, "Bills", false), pick("3", "2", 1, "Dolphins", false),
+		pick("5", "1", 1, "Bills", false), pick("5", "2", 1, "Dolphins", false), pick("5", "3", 1, "Eagles", false),
+
+		pick("1", "4", 2, "Jets", false), pick("1", "5", 2, "Giants", false),
+		pick("3", "4", 2, "Bills", true), pick("3", "5", 2, "Eagles", true),
+		pick("4", "4", 2, "Bills", true),
+	}
+	for _, uid := range []string{"1", "2", "3", "4", "5"} {
+		store.Emails[uid] = "user" + uid + "@example.com"
+	}
+	return store
+}
+
+// useSettings sets the viper keys Week reads, with overrides applied,
+// until the test ends
+func useSettings(t *testing.T, overrides map[string]interface{}) {
+	t.Helper()
+	settings := map[string]interface{}{
+		"consensus.upset_threshold": 50,
+		"leaderboard.rank_by":       "correct_picks",
+		"leaderboard.tie_breakers":  "",
+		"report.upsets":             5,
+	}
+	for key, value := range overrides {
+		settings[key] = value
+	}
+	for key, value := range settings {
+		viper.Set(key, value)
+		key := key
+		t.Cleanup(func() { viper.Set(key, nil) })
+	}
+}
+
+func score(uid string, correct, picks, missed int) Score {
+	return Score{UserID: uid, UserEmail: "user" + uid + "@example.com", Correct: correct, Picks: picks, Missed: missed}
+}
+
+func TestWeek(t *testing.T) {
+	useSettings(t, nil)
+	r, err := Week(context.Background(), weekStore(), "2425", 1, "share")
+	if err != nil {
+		t.Fatalf("Week: %v", err)
+	}
+
+	if r.Games != 3 {
+		t.Errorf("Games = %d, want 3", r.Games)
+	}
+	scores := []Score{score("1", 3, 3, 0), score("2", 1, 3, 0), score("3", 0, 2, 1), score("5", 0, 3, 0)}
+	for _, tt := range []struct {
+		name      string
+		got, want []Score
+	}{
+		{"Scores", r.Scores, scores},
+		{"Winners", r.Winners, scores[:1]},
+		{"Perfect", r.Perfect, scores[:1]},
+		{"Highest", r.Highest, scores[:1]},
+		{"Lowest", r.Lowest, scores[2:]},
+		{"Missed", r.Missed, []Score{score("3", 0, 2, 1), score("4", 0, 0, 3)}},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s\n got: %+v\nwant: %+v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	wantUpsets := []Upset{
+		{UserID: "1", UserEmail: "user1@example.com", GameID: "2", Team: "Patriots", Opponent: "Dolphins", Share: 25},
+		{UserID: "1", UserEmail: "user1@example.com", GameID: "3", Team: "Giants", Opponent: "Eagles", Share: 33},
+	}
+	if !reflect.DeepEqual(r.Upsets, wantUpsets) {
+		t.Errorf("Upsets\n got: %+v\nwant: %+v", r.Upsets, wantUpsets)
+	}
+
+	// There is no week before week 1 to move from
+	var standings []string
+	for _, e := range r.Standings {
+		standings = append(standings, e.UserID)
+		if e.Movement != nil {
+			t.Errorf("userID %s has movement %d in week 1", e.UserID, *e.Movement)
+		}
+	}
+	if want := []string{"1", "2", "3", "5"}; !reflect.DeepEqual(standings, want) {
+		t.Errorf("Standings = %v, want %v", standings, want)
+	}
+}
+
+func TestWeekUpsetLimit(t *testing.T) {
+	useSettings(t, map[string]interface{}{"report.upsets": 1})
+	r, err := Week(context.Background(), weekStore(), "2425", 1, "share")
+	if err != nil {
+		t.Fatalf("Week: %v", err)
+	}
+	if len(r.Upsets) != 1 || r.Upsets[0].GameID != "2" {
+		t.Errorf("Upsets = %+v, want only the rarest, on game 2", r.Upsets)
+	}
+}
+
+func TestWeekStandingsMovement(t *testing.T) {
+	useSettings(t, nil)
+	r, err := Week(context.Background(), weekStore(), "2425", 2, "share")
+	if err != nil {
+		t.Fatalf("Week: %v", err)
+	}
+
+	type place struct {
+		uid      string
+		rank     int
+		movement interface{}
+		gap      float64
+	}
+	var got []place
+	for _, e := range r.Standings {
+		var movement interface{}
+		if e.Movement != nil {
+			movement = *e.Movement
+		}
+		got = append(got, place{e.UserID, e.Rank, movement, e.Gap})
+	}
+	// 3 catches 2 on correct picks and 4 passes 5; 4 has no week 1 to
+	// compare with
+	want := []place{{"1", 1, 0, 0}, {"2", 2, 0, 1}, {"3", 2, 1, 1}, {"4", 4, nil, 2}, {"5", 5, -2, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Standings\n got: %+v\nwant: %+v", got, want)
+	}
+
+	if want := []Score{score("3", 2, 2, 0)}; !reflect.DeepEqual(r.Winners, want) {
+		t.Errorf("Winners\n got: %+v\nwant: %+v", r.Winners, want)
+	}
+}
+
+func TestWeekRejectsUnscoredWeek(t *testing.T) {
+	useSettings(t, nil)
+	_, err := Week(context.Background(), weekStore(), "2425", 3, "share")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 games scored") {
+		t.Errorf("Week error = %v, want 1 of 2 games scored", err)
+	}
+	_, err = Week(context.Background(), weekStore(), "2425", 4, "share")
+	if err == nil || !strings.Contains(err.Error(), "has no games") {
+		t.Errorf("Week error = %v, want has no games", err)
+	}
+}
+
+func TestWeekRejectsNegativeUpsets(t *testing.T) {
+	useSettings(t, map[string]interface{}{"report.upsets": -1})
+	_, err := Week(context.Background(), weekStore(), "2425", 1, "share")
+	if err == nil || !strings.Contains(err.Error(), "report.upsets") {
+		t.Errorf("Week error = %v, want a report.upsets error", err)
+	}
+}
+
+func TestBuiltInTemplates(t *testing.T) {
+	useSettings(t, nil)
+	r, err := Week(context.Background(), weekStore(), "2425", 1, "share")
+	if err != nil {
+		t.Fatalf("Week: %v", err)
+	}
+
+	for _, tt := range []struct {
+		format string
+		want   []string
+	}{
+		{FormatMarkdown, []string{
+			"# Week 1 Recap, Season 2425",
+			"- **user1@example.com** with 3 of 3 correct",
+			"user1@example.com took Patriots over Dolphins, picked by 25% of the league",
+			"- user4@example.com: 3 missed",
+			"| 1 | - | user1@example.com | 0 | 3 | 100 |",
+		}},
+		{FormatHTML, []string{
+			"<h1>Week 1 Recap, Season 2425</h1>",
+			"<li><strong>user1@example.com</strong> with 3 of 3 correct</li>",
+			"<li>user1@example.com took Patriots over Dolphins, picked by 25% of the league</li>",
+			"<li>user4@example.com: 3 missed</li>",
+			"<tr><td>1</td><td>-</td><td>user1@example.com</td><td>0</td><td>3</td><td>100</td></tr>",
+		}},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			tmpl, err := loadTemplate(tt.format, "")
+			if err != nil {
+				t.Fatalf("loadTemplate: %v", err)
+			}
+			var out bytes.Buffer
+			if err := tmpl.Execute(&out, r); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("recap does not contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "week.md.tmpl"), []byte("dir week {{.Week}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "recap.tmpl")
+	if err := os.WriteFile(file, []byte("file week {{.Week}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	useSettings(t, map[string]interface{}{"report.template_dir": dir})
+
+	for _, tt := range []struct {
+		name, format, path, want string
+	}{
+		{"template_dir", FormatMarkdown, "", "dir week 7"},
+		{"template file", FormatMarkdown, file, "file week 7"},
+		// The directory has no HTML template, so the built-in one is used
+		{"built-in fallback", FormatHTML, "", "<h1>Week 7 Recap, Season 2425</h1>"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := loadTemplate(tt.format, tt.path)
+			if err != nil {
+				t.Fatalf("loadTemplate: %v", err)
+			}
+			var out bytes.Buffer
+			if err := tmpl.Execute(&out, &WeekReport{Season: "2425", Week: 7}); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package season
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// Auto is the app.season.current value that detects the current season
+// from the games table
+const Auto = "auto"
+
+// codePattern matches a YYZZ season code
+var codePattern = regexp.MustCompile(`^[0-9]{4}$`)
+
+// Season is an NFL season, identified on the site by a YYZZ code such as
+// 2425 for the 2024-2025 season
+type Season struct {
+	start int // four digit year the season starts in
+}
+
+// Parse parses a YYZZ season code. ZZ must be the year after YY.
+func Parse(code string) (Season, error) {
+	if !codePattern.MatchString(code) {
+		return Season{}, fmt.Errorf("invalid season %q: expected a YYZZ code such as 2425", code)
+	}
+	start, _ := strconv.Atoi(code[:2])
+	end, _ := strconv.Atoi(code[2:])
+	if end != (start+1)%100 {
+		return Season{}, fmt.Errorf("invalid season %q: %02d does not follow %02d", code, end, start)
+	}
+	return Season{start: 2000 + start}, nil
+}
+
+// String returns the YYZZ code
+func (s Season) String() string {
+	return fmt.Sprintf("%02d%02d", s.start%100, (s.start+1)%100)
+}
+
+// StartYear returns the year the season starts in, e.g. 2024 for 2425
+func (s Season) StartYear() int {
+	return s.start
+}
+
+// IsZero reports whether s is the zero Season
+func (s Season) IsZero() bool {
+	return s.start == 0
+}
+
+// Next returns the following season
+func (s Season) Next() Season {
+	return Season{start: s.start + 1}
+}
+
+// Prev returns the preceding season
+func (s Season) Prev() Season {
+	return Season{start: s.start - 1}
+}
+
+// Before reports whether s is earlier than other
+func (s Season) Before(other Season) bool {
+	return s.start < other.start
+}
+
+// ParseRange parses a single season code or an inclusive range such as
+// 2122..2425 and returns every season it covers, oldest first
+func ParseRange(spec string) ([]Season, error) {
+	from, to, isRange := strings.Cut(spec, "..")
+	first, err := Parse(strings.TrimSpace(from))
+	if err != nil {
+		return nil, err
+	}
+	if !isRange {
+		return []Season{first}, nil
+	}
+
+	last, err := Parse(strings.TrimSpace(to))
+	if err != nil {
+		return nil, err
+	}
+	if last.Before(first) {
+		return nil, fmt.Errorf("invalid season range %q: %s is before %s", spec, last, first)
+	}
+
+	var seasons []Season
+	for s := first; !last.Before(s); s = s.Next() {
+		seasons = append(seasons, s)
+	}
+	return seasons, nil
+}
+
+// Detect returns the newest season that has games in
+// pickem_api_gamesandscores
+func Detect(ctx context.Context, store dbUtil.Store) (Season, error) {
+	codes, err := store.GameSeasons(ctx)
+	if err != nil {
+		return Season{}, err
+	}
+
+	var seasons []Season
+	for _, code := range codes {
+		s, err := Parse(code)
+		if err != nil {
+			log.Printf("Ignoring games with unrecognised season %q", code)
+			continue
+		}
+		seasons = append(seasons, s)
+	}
+	if len(seasons) == 0 {
+		return Season{}, fmt.Errorf("no games found to detect the current season from")
+	}
+
+	sort.Slice(seasons, func(i, j int) bool { return seasons[i].Before(seasons[j]) })
+	return seasons[len(seasons)-1], nil
+}
+
+func init() {
+	config.RegisterCheck("app.season.current", CheckCurrent)
+}
+
+// CheckCurrent returns an error if current is neither Auto nor a valid
+// season code
+func CheckCurrent(current string) error {
+	if strings.EqualFold(current, Auto) {
+		return nil
+	}
+	if _, err := Parse(current); err != nil {
+		return fmt.Errorf("%w (or use %q)", err, Auto)
+	}
+	return nil
+}
+
+// Current returns the season statistics are computed for. With
+// app.season.current set to "auto" it is detected from the games table;
+// otherwise the configured season is used, with a warning when it is not
+// the newest season that has games.
+func Current(ctx context.Context, store dbUtil.Store) (Season, error) {
+	configured := viper.GetString("app.season.current")
+	detected, detectErr := Detect(ctx, store)
+
+	if strings.EqualFold(configured, Auto) {
+		if detectErr != nil {
+			return Season{}, fmt.Errorf("could not detect the current season: %w", detectErr)
+		}
+		return detected, nil
+	}
+
+	current, err := Parse(configured)
+	if err != nil {
+		return Season{}, fmt.Errorf("app.season.current: %w", err)
+	}
+	if detectErr == nil && detected != current {
+		log.Printf("Warning: app.season.current is %s but the newest season with games is %s", current, detected)
+	}
+	return current, nil
+}
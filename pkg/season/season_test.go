@@ -0,0 +1,144 @@
+package season
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		start   int
+		wantErr bool
+	}{
+		{code: "2425", want: "2425", start: 2024},
+		{code: "0001", want: "0001", start: 2000},
+		{code: "9900", want: "9900", start: 2099},
+		{code: "2426", wantErr: true},
+		{code: "2524", wantErr: true},
+		{code: "2424", wantErr: true},
+		{code: "24-25", wantErr: true},
+		{code: "24ab", wantErr: true},
+		{code: "242", wantErr: true},
+		{code: "24250", wantErr: true},
+		{code: "", wantErr: true},
+		{code: " 2425", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			s, err := Parse(tt.code)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%q) = %s, want an error", tt.code, s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.code, err)
+			}
+			if s.String() != tt.want || s.StartYear() != tt.start {
+				t.Errorf("Parse(%q) = %s starting %d, want %s starting %d", tt.code, s, s.StartYear(), tt.want, tt.start)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{spec: "2425", want: []string{"2425"}},
+		{spec: "2122..2425", want: []string{"2122", "2223", "2324", "2425"}},
+		{spec: "2425..2425", want: []string{"2425"}},
+		{spec: " 2324 .. 2425 ", want: []string{"2324", "2425"}},
+		{spec: "9900..0102", wantErr: true},
+		{spec: "2425..2122", wantErr: true},
+		{spec: "2122..2426", wantErr: true},
+		{spec: "2123..2425", wantErr: true},
+		{spec: "2122..", wantErr: true},
+		{spec: "..2425", wantErr: true},
+		{spec: "all", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			seasons, err := ParseRange(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRange(%q) = %v, want an error", tt.spec, seasons)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRange(%q): %v", tt.spec, err)
+			}
+			var got []string
+			for _, s := range seasons {
+				got = append(got, s.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRange(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	store := dbUtil.NewMemoryStore()
+	if _, err := Detect(context.Background(), store); err == nil {
+		t.Error("Detect found a season without any games")
+	}
+
+	// The newest valid season wins, whatever order the games are in
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2324"},
+		{ID: "2", Season: "2425"},
+		{ID: "3", Season: "2122"},
+		{ID: "4", Season: "2527x"},
+		{ID: "5", Season: ""},
+	}
+	s, err := Detect(context.Background(), store)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if s.String() != "2425" {
+		t.Errorf("Detect = %s, want 2425", s)
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{{ID: "1", Season: "2324"}, {ID: "2", Season: "2425"}}
+	t.Cleanup(func() { viper.Set("app.season.current", nil) })
+
+	for _, tt := range []struct {
+		configured, want string
+	}{
+		{configured: "auto", want: "2425"},
+		{configured: "AUTO", want: "2425"},
+		{configured: "2324", want: "2324"},
+	} {
+		viper.Set("app.season.current", tt.configured)
+		s, err := Current(context.Background(), store)
+		if err != nil {
+			t.Fatalf("Current(%s): %v", tt.configured, err)
+		}
+		if s.String() != tt.want {
+			t.Errorf("Current(%s) = %s, want %s", tt.configured, s, tt.want)
+		}
+	}
+
+	viper.Set("app.season.current", "2324x")
+	if _, err := Current(context.Background(), store); err == nil {
+		t.Error("Current accepted app.season.current 2324x")
+	}
+}
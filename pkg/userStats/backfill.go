@@ -0,0 +1,206 @@
+package userStats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// addSeasonFlags adds the --season and --seasons backfill flags to cmd
+func addSeasonFlags(cmd *cobra.Command) {
+	cmd.Flags().String("season", "", "Backfill a past season (YYZZ) into pickemcli_userstats_by_season instead of updating pickem_api_userstats")
+	cmd.Flags().String("seasons", "", "Backfill a range of seasons, e.g. 2122..2425")
+	cmd.MarkFlagsMutuallyExclusive("season", "seasons")
+}
+
+// backfillSeasons returns the seasons asked for with --season or
+// --seasons, or nil for a normal run
+func backfillSeasons(cmd *cobra.Command) ([]season.Season, error) {
+	for _, name := range []string{"season", "seasons"} {
+		spec, err := cmd.Flags().GetString(name)
+		if err != nil {
+			return nil, err
+		}
+		if spec != "" {
+			seasons, err := season.ParseRange(spec)
+			if err != nil {
+				return nil, fmt.Errorf("--%s: %w", name, err)
+			}
+			return seasons, nil
+		}
+	}
+	return nil, nil
+}
+
+// runCommand connects to the database and runs collectors. Normally that
+// is one run for the current season; with --season or --seasons it is one
+// backfill run per season, writing only the by-season history. With
+// --dry-run nothing is written and the changes are printed instead;
+// otherwise the records written are printed in the --output format.
+func runCommand(cmd *cobra.Command, collectors ...collector) error {
+	ctx := dbUtil.WithRunID(cmd.Context(), uuid.NewString())
+
+	seasons, err := backfillSeasons(cmd)
+	if err != nil {
+		return err
+	}
+	if viper.GetBool("dry_run") {
+		if err := checkDiffFormat(); err != nil {
+			return err
+		}
+	} else if err := output.Check(output.Format()); err != nil {
+		return err
+	}
+
+	database, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if viper.GetBool("dry_run") {
+		return dryRun(cmd, database, seasons, collectors)
+	}
+	log.Printf("Run ID: %s", dbUtil.RunID(ctx))
+	// Backfills never touch pickem_api_userstats, so there is nothing to save
+	if viper.GetBool("snapshot.auto") && len(seasons) == 0 {
+		if err := dbUtil.AutoSnapshot(ctx, database, dbUtil.SnapshotRun, viper.GetInt("snapshot.keep")); err != nil {
+			return fmt.Errorf("not running, the snapshot failed: %w", err)
+		}
+	}
+	results, runErr := runSeasons(ctx, dbUtil.NewPostgresStore(database), seasons, collectors)
+
+	// Print whatever was written, even if some collectors failed
+	if err := output.Write(cmd.OutOrStdout(), output.Format(), resultsTable(results)); err != nil {
+		return errors.Join(runErr, err)
+	}
+	return runErr
+}
+
+// seasonResults is the records written for one season
+type seasonResults struct {
+	season season.Season
+	stats  []*dbUtil.UserStats
+}
+
+// runSeasons runs collectors against store for the current season, or
+// for each of seasons as a backfill. The Django tables are read once for
+// all of them.
+func runSeasons(ctx context.Context, store dbUtil.Store, seasons []season.Season, collectors []collector) ([]seasonResults, error) {
+	store = dbUtil.NewCachedStore(store)
+	if len(seasons) == 0 {
+		current, err := season.Current(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := runCollectors(ctx, &seasonHistoryStore{Store: store, season: current}, current, collectors)
+		return []seasonResults{{current, stats}}, err
+	}
+
+	var results []seasonResults
+	var errs []error
+	for _, s := range seasons {
+		log.Printf("Backfilling season %s", s)
+		stats, err := runCollectors(ctx, &seasonHistoryStore{Store: store, season: s, backfill: true}, s, collectors)
+		results = append(results, seasonResults{s, stats})
+		if err == nil {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("season %s: %w", s, err))
+		if ctx.Err() != nil || db.IsConnectionError(err) {
+			break
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// resultsTable lays out results as one row per season and user. Only the
+// columns some collector set are included.
+func resultsTable(results []seasonResults) output.Table {
+	var names []string
+	used := make(map[string]bool)
+	for _, r := range results {
+		for _, stats := range r.stats {
+			for _, field := range stats.StatFields() {
+				if field.Value != nil {
+					used[field.Name] = true
+				}
+			}
+		}
+	}
+	for _, field := range (&dbUtil.UserStats{}).StatFields() {
+		if used[field.Name] {
+			names = append(names, field.Name)
+		}
+	}
+
+	t := output.Table{Columns: append([]string{"season", "userID"}, names...)}
+	for _, r := range results {
+		for _, stats := range r.stats {
+			row := []interface{}{r.season.String(), stats.UserID}
+			for _, field := range stats.StatFields() {
+				if used[field.Name] {
+					row = append(row, field.Value)
+				}
+			}
+			t.Append(row...)
+		}
+	}
+	return t
+}
+
+// seasonHistoryStore records the season fields of every UserStats upsert
+// as that season's row in pickemcli_userstats_by_season, for users with at
+// least one pick in the season. In backfill mode pickem_api_userstats
+// itself is left alone, since its *Season fields describe the current
+// season only.
+type seasonHistoryStore struct {
+	dbUtil.Store
+	season   season.Season
+	backfill bool
+
+	// pickers holds the users with a pick in season, read on first use
+	pickers map[string]bool
+}
+
+// UpsertUserStats writes batch to pickem_api_userstats (unless
+// backfilling) and, for users who picked in the season, to the by-season
+// history
+func (s *seasonHistoryStore) UpsertUserStats(ctx context.Context, batch []*dbUtil.UserStats) error {
+	if !s.backfill {
+		if err := s.Store.UpsertUserStats(ctx, batch); err != nil {
+			return err
+		}
+	}
+
+	if s.pickers == nil {
+		picks, err := s.Store.Picks(ctx, dbUtil.PickFilter{Season: s.season.String()})
+		if err != nil {
+			return fmt.Errorf("error getting season %s picks: %w", s.season, err)
+		}
+		s.pickers = make(map[string]bool)
+		for _, p := range picks {
+			s.pickers[p.UID] = true
+		}
+	}
+
+	var history []*dbUtil.SeasonStats
+	for _, stats := range batch {
+		if s.pickers[stats.UserID] {
+			history = append(history, dbUtil.NewSeasonStats(stats, s.season.String()))
+		}
+	}
+	if len(history) == 0 {
+		return nil
+	}
+	return s.Store.UpsertSeasonStats(ctx, history)
+}
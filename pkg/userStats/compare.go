@@ -0,0 +1,275 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+)
+
+// Comparison is the head-to-head record of two users. Shared games are
+// games both users picked; the records and weeks only count graded picks.
+type Comparison struct {
+	UserA, UserB string
+	// Season is the season compared, or empty for all time
+	Season string
+
+	SharedGames int
+	SameSide    int
+	// Agreement is SameSide as a whole percentage of SharedGames
+	Agreement int
+
+	// Disagreements counts the graded shared games the users picked
+	// differently, and CorrectA and CorrectB how many of them each got right
+	Disagreements      int
+	CorrectA, CorrectB int
+
+	// WeeksA and WeeksB count the weeks both users had graded picks in
+	// where each had more correct picks than the other, and WeeksTied the
+	// weeks they were level. WeeksOnlyA and WeeksOnlyB count the weeks only
+	// that user had graded picks in, which are not compared.
+	WeeksA, WeeksB, WeeksTied int
+	WeeksOnlyA, WeeksOnlyB    int
+
+	// WeeksWonA and WeeksWonB count the weeks each user is flagged as the
+	// league's winner in pickem_api_userseasonpoints
+	WeeksWonA, WeeksWonB int
+
+	// StatsA and StatsB are the users' pickem_api_userstats rows, nil when
+	// a user has none. They are only read for all-time comparisons.
+	StatsA, StatsB *dbUtil.UserStats
+	// SeasonStatsA and SeasonStatsB are the users' Season rows of
+	// pickemcli_userstats_by_season, nil when a user has none. They are
+	// only read when comparing a season.
+	SeasonStatsA, SeasonStatsB *dbUtil.SeasonStats
+}
+
+// Compare represents the compare command
+var Compare = &cobra.Command{
+	Use:   "compare <uidA> <uidB>",
+	Short: "Compare two users head to head",
+	Long: `Head-to-Head Comparison
+			Show how often two users picked the same side, their records on the games
+			where they disagreed, how many weeks each beat the other, and their user
+			statistics side by side: all time, or the by-season history with --season`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		seasonCode, _ := cmd.Flags().GetString("season")
+		if seasonCode != "" {
+			s, err := season.Parse(seasonCode)
+			if err != nil {
+				return fmt.Errorf("--season: %w", err)
+			}
+			seasonCode = s.String()
+		}
+		if args[0] == args[1] {
+			return fmt.Errorf("cannot compare userID %s with itself", args[0])
+		}
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		c, err := CompareUsers(ctx, dbUtil.NewPostgresStore(database), args[0], args[1], seasonCode)
+		if err != nil {
+			return err
+		}
+		return output.Write(cmd.OutOrStdout(), output.Format(), comparisonTable(c))
+	},
+}
+
+func init() {
+	Compare.Flags().String("season", "", "Only compare picks from this season (YYZZ) (default: all time)")
+}
+
+// CompareUsers works out the head-to-head record of users a and b in
+// seasonCode, or all time when it is empty
+func CompareUsers(ctx context.Context, store dbUtil.Store, a, b, seasonCode string) (*Comparison, error) {
+	picksA, err := store.Picks(ctx, dbUtil.PickFilter{UID: a, Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks for userID %s: %w", a, err)
+	}
+	picksB, err := store.Picks(ctx, dbUtil.PickFilter{UID: b, Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks for userID %s: %w", b, err)
+	}
+	if len(picksA) == 0 && len(picksB) == 0 {
+		return nil, fmt.Errorf("neither userID %s nor %s has any picks", a, b)
+	}
+	points, err := store.SeasonPoints(ctx, seasonCode)
+	if err != nil {
+		return nil, fmt.Errorf("error getting season points: %w", err)
+	}
+
+	c := &Comparison{UserA: a, UserB: b, Season: seasonCode}
+	if seasonCode == "" {
+		stats, err := store.UserStats(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting user stats: %w", err)
+		}
+		for _, s := range stats {
+			switch s.UserID {
+			case a:
+				c.StatsA = s
+			case b:
+				c.StatsB = s
+			}
+		}
+	} else {
+		stats, err := store.UserSeasonStats(ctx, seasonCode)
+		if err != nil {
+			return nil, fmt.Errorf("error getting season %s stats: %w", seasonCode, err)
+		}
+		for _, s := range stats {
+			switch s.UserID {
+			case a:
+				c.SeasonStatsA = s
+			case b:
+				c.SeasonStatsB = s
+			}
+		}
+	}
+
+	for _, sp := range points {
+		switch sp.UserID {
+		case a:
+			c.WeeksWonA += sp.WeeksWon()
+		case b:
+			c.WeeksWonB += sp.WeeksWon()
+		}
+	}
+
+	// Picks without a team or season are not compared, as in PickSimilarity
+	byGame := make(map[string]dbUtil.Pick, len(picksB))
+	for _, p := range picksB {
+		if p.Season == "" || dbUtil.TeamKey(p.Team) == "" {
+			continue
+		}
+		byGame[p.GameID] = p
+	}
+	for _, pa := range picksA {
+		if pa.Season == "" || dbUtil.TeamKey(pa.Team) == "" {
+			continue
+		}
+		pb, ok := byGame[pa.GameID]
+		if !ok {
+			continue
+		}
+		c.SharedGames++
+		if dbUtil.SameTeam(pa.Team, pb.Team) {
+			c.SameSide++
+			continue
+		}
+		if !pa.Graded || !pb.Graded {
+			continue
+		}
+		c.Disagreements++
+		if pa.Correct {
+			c.CorrectA++
+		}
+		if pb.Correct {
+			c.CorrectB++
+		}
+	}
+	c.Agreement = pickPercent(c.SameSide, c.SharedGames)
+
+	// Weeks both users had a graded pick in, compared on correct picks
+	weeksA, weeksB := gradedWeeks(picksA), gradedWeeks(picksB)
+	for key := range weeksB {
+		if _, ok := weeksA[key]; !ok {
+			c.WeeksOnlyB++
+		}
+	}
+	for key, correctA := range weeksA {
+		correctB, ok := weeksB[key]
+		if !ok {
+			c.WeeksOnlyA++
+			continue
+		}
+		switch {
+		case correctA > correctB:
+			c.WeeksA++
+		case correctB > correctA:
+			c.WeeksB++
+		default:
+			c.WeeksTied++
+		}
+	}
+	return c, nil
+}
+
+// gradedWeeks counts the correct picks in every week with a graded pick
+func gradedWeeks(picks []dbUtil.Pick) map[seasonWeek]int {
+	weeks := make(map[seasonWeek]int)
+	for _, p := range picks {
+		if p.Season == "" || !p.Graded {
+			continue
+		}
+		key := seasonWeek{p.Season, p.Week}
+		correct := weeks[key]
+		if p.Correct {
+			correct++
+		}
+		weeks[key] = correct
+	}
+	return weeks
+}
+
+// comparisonTable lays out c for output.Write, one row per measure with a
+// column for each user. Measures of the pair, like shared games, repeat in
+// both columns.
+func comparisonTable(c *Comparison) output.Table {
+	t := output.Table{Columns: []string{"field", c.UserA, c.UserB}}
+	scope := c.Season
+	if scope == "" {
+		scope = "all"
+	}
+	t.Append("season", scope, scope)
+	t.Append("sharedGames", c.SharedGames, c.SharedGames)
+	t.Append("sameSide", c.SameSide, c.SameSide)
+	t.Append("agreement", c.Agreement, c.Agreement)
+	t.Append("disagreements", c.Disagreements, c.Disagreements)
+	t.Append("disagreementsCorrect", c.CorrectA, c.CorrectB)
+	t.Append("weeksBeatOther", c.WeeksA, c.WeeksB)
+	t.Append("weeksTied", c.WeeksTied, c.WeeksTied)
+	t.Append("weeksNotCompared", c.WeeksOnlyA, c.WeeksOnlyB)
+	t.Append("weeksWon", c.WeeksWonA, c.WeeksWonB)
+
+	// The stored statistics of the same scope
+	var fieldsA, fieldsB []dbUtil.StatField
+	if c.Season == "" {
+		fieldsA, fieldsB = userStatFields(c.StatsA), userStatFields(c.StatsB)
+	} else {
+		fieldsA, fieldsB = seasonStatFields(c.SeasonStatsA), seasonStatFields(c.SeasonStatsB)
+	}
+	for i, field := range fieldsA {
+		t.Append(field.Name, field.Value, fieldsB[i].Value)
+	}
+	return t
+}
+
+// userStatFields returns the StatFields of stats, all nil when stats is nil
+func userStatFields(stats *dbUtil.UserStats) []dbUtil.StatField {
+	if stats == nil {
+		stats = &dbUtil.UserStats{}
+	}
+	return stats.StatFields()
+}
+
+// seasonStatFields returns the StatFields of stats, all nil when stats is nil
+func seasonStatFields(stats *dbUtil.SeasonStats) []dbUtil.StatField {
+	if stats == nil {
+		stats = &dbUtil.SeasonStats{}
+	}
+	return stats.StatFields()
+}
@@ -0,0 +1,85 @@
+package userStats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+func TestCompareUsers(t *testing.T) {
+	ctx := context.Background()
+	store := fixtureStore()
+	current := mustSeason(t, "2425")
+	if _, err := runCollectors(ctx, &seasonHistoryStore{Store: store, season: current}, current, allCollectors); err != nil {
+		t.Fatalf("runCollectors: %v", err)
+	}
+
+	tests := []struct {
+		season string
+		want   Comparison
+	}{
+		{
+			season: "",
+			want: Comparison{
+				SharedGames: 2, Disagreements: 2, CorrectA: 1, CorrectB: 1,
+				WeeksTied: 1, WeeksOnlyA: 2, WeeksWonA: 2, WeeksWonB: 1,
+			},
+		},
+		{
+			season: "2425",
+			want: Comparison{
+				SharedGames: 2, Disagreements: 2, CorrectA: 1, CorrectB: 1,
+				WeeksTied: 1, WeeksOnlyA: 1, WeeksWonA: 1, WeeksWonB: 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("season "+tt.season, func(t *testing.T) {
+			c, err := CompareUsers(ctx, store, "1", "2", tt.season)
+			if err != nil {
+				t.Fatalf("CompareUsers: %v", err)
+			}
+			got := *c
+			got.UserA, got.UserB, got.Season = "", "", ""
+			got.StatsA, got.StatsB, got.SeasonStatsA, got.SeasonStatsB = nil, nil, nil, nil
+			if got != tt.want {
+				t.Errorf("CompareUsers\n got: %+v\nwant: %+v", got, tt.want)
+			}
+
+			// The stored statistics come from the same scope
+			rows := make(map[string][]interface{})
+			for _, row := range comparisonTable(c).Rows {
+				rows[row[0].(string)] = row[1:]
+			}
+			field, correctA, correctB := "correctPickTotalTotal", 4, 1
+			if tt.season != "" {
+				field, correctA, correctB = "correctPickTotal", 2, 1
+			}
+			if row := rows[field]; len(row) != 2 || row[0] != correctA || row[1] != correctB {
+				t.Errorf("%s row = %v, want [%d %d]", field, row, correctA, correctB)
+			}
+		})
+	}
+}
+
+func TestCompareUsersSkipsBlankPicks(t *testing.T) {
+	store := fixtureStore()
+	store.GameRows = append(store.GameRows, dbUtil.Game{ID: "14", Season: "2425", Week: 2, HomeTeam: "A", AwayTeam: "D"})
+	store.PickRows = append(store.PickRows,
+		// Both picked game 14 without a team, and 1 picked game 13 on a row
+		// missing its season
+		dbUtil.Pick{UID: "1", Season: "2425", Week: 2, GameID: "14", Team: " "},
+		dbUtil.Pick{UID: "2", Season: "2425", Week: 2, GameID: "14"},
+		dbUtil.Pick{UID: "1", Week: 2, GameID: "13", Team: "B"},
+	)
+
+	c, err := CompareUsers(context.Background(), store, "1", "2", "2425")
+	if err != nil {
+		t.Fatalf("CompareUsers: %v", err)
+	}
+	if c.SharedGames != 2 || c.SameSide != 0 || c.Agreement != 0 {
+		t.Errorf("shared %d, same side %d, agreement %d; want 2, 0 and 0", c.SharedGames, c.SameSide, c.Agreement)
+	}
+}
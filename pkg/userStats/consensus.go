@@ -0,0 +1,309 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ConsensusAllTime is the consensus --season value covering every season
+const ConsensusAllTime = "all"
+
+// ContrarianStats is how often one user picked against the league
+// majority, and how that went. Only graded picks on games where one side
+// had a majority count.
+type ContrarianStats struct {
+	UserID    string
+	UserEmail string
+	Picks     int
+	// Contrarian is the picks of the side fewer than half the league took
+	Contrarian        int
+	ContrarianCorrect int
+	// ContrarianRate and ContrarianAccuracy are whole percentages of
+	// Picks and of Contrarian
+	ContrarianRate     int
+	ContrarianAccuracy int
+	UpsetCalls         int
+}
+
+// UpsetCall is a correct pick that less than the upset threshold of the
+// league made
+type UpsetCall struct {
+	Season string
+	Week   int
+	GameID string
+	UserID string
+	Team   string
+	// Share is the whole percentage of the game's picks that took Team
+	Share int
+}
+
+// ConsensusReport is everything Consensus works out for a scope
+type ConsensusReport struct {
+	Games  []dbUtil.GameConsensus
+	Users  []ContrarianStats
+	Upsets []UpsetCall
+}
+
+// CheckUpsetThreshold returns an error unless threshold is a percentage
+// between 1 and 50
+func CheckUpsetThreshold(threshold int) error {
+	if threshold < 1 || threshold > 50 {
+		return fmt.Errorf("must be between 1 and 50, got %d", threshold)
+	}
+	return nil
+}
+
+// ConsensusCmd represents the consensus command
+var ConsensusCmd = &cobra.Command{
+	Use:   "consensus",
+	Short: "Compute league consensus per game and contrarian picks",
+	Long: `League Consensus
+			For every game, work out what share of the league picked each side and
+			store it in pickemcli_game_consensus. For every user, report how often
+			they picked against the majority, how accurate those picks were, and
+			their upset calls: correct picks too few of the league made`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope, _ := cmd.Flags().GetString("season")
+		scope, err := parseScope(scope, ConsensusAllTime)
+		if err != nil {
+			return err
+		}
+		threshold := viper.GetInt("consensus.upset_threshold")
+		if err := CheckUpsetThreshold(threshold); err != nil {
+			return fmt.Errorf("consensus.upset_threshold: %w", err)
+		}
+		games, _ := cmd.Flags().GetBool("games")
+		upsets, _ := cmd.Flags().GetBool("upsets")
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		store, done, err := openStore(ctx, database)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		if scope, err = resolveScope(ctx, store, scope); err != nil {
+			return err
+		}
+
+		report, err := Consensus(ctx, store, scope, threshold)
+		if err != nil {
+			return err
+		}
+		if err := storeConsensus(ctx, store, scope, report.Games); err != nil {
+			return err
+		}
+
+		switch {
+		case games:
+			return output.Write(cmd.OutOrStdout(), output.Format(), consensusTable(report.Games))
+		case upsets:
+			return output.Write(cmd.OutOrStdout(), output.Format(), upsetTable(report.Upsets))
+		}
+		return output.Write(cmd.OutOrStdout(), output.Format(), contrarianTable(report.Users))
+	},
+}
+
+func init() {
+	ConsensusCmd.Flags().String("season", "", `Season to compute (YYZZ), or "all" for every season (default: the current season)`)
+	ConsensusCmd.Flags().Int("upset-threshold", 0, "Largest share of the league, in percent, whose correct pick is an upset call (default: consensus.upset_threshold)")
+	ConsensusCmd.Flags().Bool("games", false, "Print the consensus of every game instead of the per-user statistics")
+	ConsensusCmd.Flags().Bool("upsets", false, "Print every upset call instead of the per-user statistics")
+	ConsensusCmd.MarkFlagsMutuallyExclusive("games", "upsets")
+	if err := viper.BindPFlag("consensus.upset_threshold", ConsensusCmd.Flags().Lookup("upset-threshold")); err != nil {
+		panic(err.Error())
+	}
+	config.RegisterIntCheck("consensus.upset_threshold", CheckUpsetThreshold)
+}
+
+// UpdateConsensus stores the consensus of every game in the current season
+func UpdateConsensus(ctx context.Context, store dbUtil.Store, current season.Season) error {
+	report, err := Consensus(ctx, store, current.String(), viper.GetInt("consensus.upset_threshold"))
+	if err != nil {
+		return err
+	}
+	return storeConsensus(ctx, store, current.String(), report.Games)
+}
+
+// storeConsensus replaces the stored consensus of scope's season, or of
+// every season in games when scope is ConsensusAllTime
+func storeConsensus(ctx context.Context, store dbUtil.Store, scope string, games []dbUtil.GameConsensus) error {
+	if scope != ConsensusAllTime {
+		return store.ReplaceGameConsensus(ctx, scope, games)
+	}
+	bySeason := make(map[string][]dbUtil.GameConsensus)
+	for _, g := range games {
+		bySeason[g.Season] = append(bySeason[g.Season], g)
+	}
+	for _, code := range sortedKeys(bySeason) {
+		if err := store.ReplaceGameConsensus(ctx, code, bySeason[code]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Consensus works out the league's picks of every game in scope, a season
+// or ConsensusAllTime, and each user's contrarian picks and upset calls.
+// The game consensus counts every pick, including games still to be
+// played; the user statistics only count graded picks. A side picked by
+// more than half of a game's picks is the majority, and a game split
+// evenly has none. A correct pick whose side had less than threshold
+// percent of the game's picks is an upset call.
+func Consensus(ctx context.Context, store dbUtil.Store, scope string, threshold int) (*ConsensusReport, error) {
+	if err := CheckUpsetThreshold(threshold); err != nil {
+		return nil, fmt.Errorf("upset threshold: %w", err)
+	}
+	seasonFilter := scope
+	if scope == ConsensusAllTime {
+		seasonFilter = ""
+	}
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonFilter})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonFilter})
+	if err != nil {
+		return nil, fmt.Errorf("error getting games: %w", err)
+	}
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
+	}
+
+	// Picks of each team, per game, matched by dbUtil.TeamKey like grading
+	total := make(map[string]int)
+	byTeam := make(map[string]map[string]int)
+	for _, p := range picks {
+		key := dbUtil.TeamKey(p.Team)
+		if p.Season == "" || key == "" {
+			continue
+		}
+		if byTeam[p.GameID] == nil {
+			byTeam[p.GameID] = make(map[string]int)
+		}
+		byTeam[p.GameID][key]++
+		total[p.GameID]++
+	}
+	names := teamNames(games)
+
+	report := &ConsensusReport{}
+	sort.Slice(games, func(i, j int) bool { return gameLess(games[i], games[j]) })
+	known := make(map[string]dbUtil.Game, len(games))
+	for _, g := range games {
+		known[g.ID] = g
+		c := dbUtil.GameConsensus{
+			GameID:    g.ID,
+			Season:    g.Season,
+			Week:      g.Week,
+			HomeTeam:  g.HomeTeam,
+			AwayTeam:  g.AwayTeam,
+			Picks:     total[g.ID],
+			HomePicks: byTeam[g.ID][dbUtil.TeamKey(g.HomeTeam)],
+			AwayPicks: byTeam[g.ID][dbUtil.TeamKey(g.AwayTeam)],
+		}
+		c.HomePercent = pickPercent(c.HomePicks, c.Picks)
+		c.AwayPercent = pickPercent(c.AwayPicks, c.Picks)
+		report.Games = append(report.Games, c)
+	}
+
+	byUID := make(map[string]*ContrarianStats)
+	for _, p := range picks {
+		g, ok := known[p.GameID]
+		key := dbUtil.TeamKey(p.Team)
+		if !ok || key == "" || !p.Graded {
+			continue
+		}
+		count, n := byTeam[p.GameID][key], total[p.GameID]
+
+		s, ok := byUID[p.UID]
+		if !ok {
+			s = &ContrarianStats{UserID: p.UID, UserEmail: dbUtil.EmailFor(emails, p.UID)}
+			byUID[p.UID] = s
+		}
+		if count*2 != n {
+			s.Picks++
+		}
+		if count*2 < n {
+			s.Contrarian++
+			if p.Correct {
+				s.ContrarianCorrect++
+			}
+		}
+		if p.Correct && count*100 < threshold*n {
+			s.UpsetCalls++
+			report.Upsets = append(report.Upsets, UpsetCall{
+				Season: g.Season, Week: g.Week, GameID: g.ID, UserID: p.UID, Team: teamName(names, p.Team),
+				Share: pickPercent(count, n),
+			})
+		}
+	}
+	for _, uid := range sortedKeys(byUID) {
+		s := byUID[uid]
+		s.ContrarianRate = pickPercent(s.Contrarian, s.Picks)
+		s.ContrarianAccuracy = pickPercent(s.ContrarianCorrect, s.Contrarian)
+		report.Users = append(report.Users, *s)
+	}
+	sort.SliceStable(report.Upsets, func(i, j int) bool {
+		a, b := report.Upsets[i], report.Upsets[j]
+		if a.GameID != b.GameID {
+			return gameLess(known[a.GameID], known[b.GameID])
+		}
+		return a.UserID < b.UserID
+	})
+	return report, nil
+}
+
+// consensusTable lays out games for output.Write
+func consensusTable(games []dbUtil.GameConsensus) output.Table {
+	t := output.Table{Columns: []string{
+		"season", "week", "gameID", "homeTeam", "awayTeam", "picks",
+		"homePicks", "awayPicks", "homePercent", "awayPercent",
+	}}
+	for _, g := range games {
+		t.Append(g.Season, g.Week, g.GameID, g.HomeTeam, g.AwayTeam, g.Picks,
+			g.HomePicks, g.AwayPicks, g.HomePercent, g.AwayPercent)
+	}
+	return t
+}
+
+// contrarianTable lays out users for output.Write
+func contrarianTable(users []ContrarianStats) output.Table {
+	t := output.Table{Columns: []string{
+		"userID", "userEmail", "picks", "contrarian", "contrarianRate",
+		"contrarianCorrect", "contrarianAccuracy", "upsetCalls",
+	}}
+	for _, u := range users {
+		t.Append(u.UserID, u.UserEmail, u.Picks, u.Contrarian, u.ContrarianRate,
+			u.ContrarianCorrect, u.ContrarianAccuracy, u.UpsetCalls)
+	}
+	return t
+}
+
+// upsetTable lays out upsets for output.Write
+func upsetTable(upsets []UpsetCall) output.Table {
+	t := output.Table{Columns: []string{"season", "week", "gameID", "userID", "team", "share"}}
+	for _, u := range upsets {
+		t.Append(u.Season, u.Week, u.GameID, u.UserID, u.Team, u.Share)
+	}
+	return t
+}
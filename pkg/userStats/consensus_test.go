@@ -0,0 +1,55 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+func TestConsensusNormalisesTeams(t *testing.T) {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2425", Week: 1, Scored: true, HomeTeam: "Jets", AwayTeam: "Bills "},
+		{ID: "2", Season: "2425", Week: 2, Scored: true, HomeTeam: " jets", AwayTeam: "Bills"},
+	}
+	store.PickRows = []dbUtil.Pick{
+		// Week 1: Jets won
+		{UID: "1", Season: "2425", Week: 1, GameID: "1", Team: "Jets", Correct: true, Graded: true},
+		{UID: "2", Season: "2425", Week: 1, GameID: "1", Team: " jets", Correct: true, Graded: true},
+		{UID: "3", Season: "2425", Week: 1, GameID: "1", Team: "JETS ", Correct: true, Graded: true},
+		{UID: "4", Season: "2425", Week: 1, GameID: "1", Team: "bills", Graded: true},
+		// Week 2: Bills won, picked by a quarter of the league
+		{UID: "1", Season: "2425", Week: 2, GameID: "2", Team: "Jets", Graded: true},
+		{UID: "2", Season: "2425", Week: 2, GameID: "2", Team: "jets", Graded: true},
+		{UID: "3", Season: "2425", Week: 2, GameID: "2", Team: "JETS", Graded: true},
+		{UID: "4", Season: "2425", Week: 2, GameID: "2", Team: " BILLS", Correct: true, Graded: true},
+	}
+
+	report, err := Consensus(context.Background(), store, "2425", 30)
+	if err != nil {
+		t.Fatalf("Consensus: %v", err)
+	}
+
+	for _, g := range report.Games {
+		if g.Picks != 4 || g.HomePicks != 3 || g.AwayPicks != 1 || g.HomePercent != 75 || g.AwayPercent != 25 {
+			t.Errorf("game %s consensus = %+v, want 3 home and 1 away of 4 picks", g.GameID, g)
+		}
+	}
+
+	wantUpsets := []UpsetCall{{Season: "2425", Week: 2, GameID: "2", UserID: "4", Team: "Bills", Share: 25}}
+	if !reflect.DeepEqual(report.Upsets, wantUpsets) {
+		t.Errorf("upsets\n got: %+v\nwant: %+v", report.Upsets, wantUpsets)
+	}
+
+	for _, u := range report.Users {
+		want := ContrarianStats{UserID: u.UserID, UserEmail: u.UserEmail, Picks: 2}
+		if u.UserID == "4" {
+			want.Contrarian, want.ContrarianCorrect, want.ContrarianRate, want.ContrarianAccuracy, want.UpsetCalls = 2, 1, 100, 50, 1
+		}
+		if u != want {
+			t.Errorf("userID %s contrarian stats = %+v, want %+v", u.UserID, u, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package userStats
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/jimdaga/pickemcli/internal/cli"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// dryRun runs collectors inside a read-only transaction with every write
+// held back, then prints how pickem_api_userstats would change. It returns
+// an ExitError when anything would change, so CI can fail on a diff.
+func dryRun(cmd *cobra.Command, database *sql.DB, seasons []season.Season, collectors []collector) error {
+	ctx := cmd.Context()
+
+	readOnly, tx, err := dbUtil.NewReadOnlyStore(ctx, database)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	store := dbUtil.NewDryRunStore(readOnly)
+	_, runErr := runSeasons(ctx, store, seasons, collectors)
+	if ctx.Err() != nil {
+		return runErr
+	}
+
+	diffs, err := store.Diff(ctx)
+	if err != nil {
+		return errors.Join(runErr, err)
+	}
+	log.Printf("Dry run: %d user(s) would change, %d by-season row(s) would be written", len(diffs), store.SeasonRows)
+
+	out := cmd.OutOrStdout()
+	if viper.GetString("diff_format") == "json" {
+		err = writeDiffJSON(out, diffs)
+	} else {
+		writeDiffText(out, diffs)
+	}
+	if err != nil || runErr != nil {
+		return errors.Join(runErr, err)
+	}
+
+	if len(diffs) > 0 {
+		return cli.Exit(cli.ExitDifferences, "dry run: %d user(s) would change", len(diffs))
+	}
+	return nil
+}
+
+// checkDiffFormat rejects an unknown --diff-format before any work is done
+func checkDiffFormat() error {
+	if format := viper.GetString("diff_format"); format != "text" && format != "json" {
+		return fmt.Errorf("--diff-format must be text or json, got %q", format)
+	}
+	return nil
+}
+
+// writeDiffText writes diffs as one block per user with a line per field
+func writeDiffText(w io.Writer, diffs []dbUtil.UserDiff) {
+	for _, diff := range diffs {
+		if diff.Created {
+			fmt.Fprintf(w, "%s (new row)\n", diff.UserID)
+		} else {
+			fmt.Fprintf(w, "%s\n", diff.UserID)
+		}
+		for _, change := range diff.Changes {
+			fmt.Fprintf(w, "  %s: %s → %s\n", change.Field, diffValue(change.Old), diffValue(change.New))
+		}
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No changes")
+	}
+}
+
+// writeDiffJSON writes diffs as an indented JSON array
+func writeDiffJSON(w io.Writer, diffs []dbUtil.UserDiff) error {
+	if diffs == nil {
+		diffs = []dbUtil.UserDiff{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diffs)
+}
+
+func diffValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,392 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Ranking keys, usable as the leaderboard key and as tie-breakers
+const (
+	RankWeeksWon     = "weeks_won"
+	RankCorrectPicks = "correct_picks"
+	RankPickPercent  = "pick_percent"
+	RankPerfectWeeks = "perfect_weeks"
+)
+
+// RankKeys lists every ranking key
+var RankKeys = []string{RankWeeksWon, RankCorrectPicks, RankPickPercent, RankPerfectWeeks}
+
+// LeaderboardOptions selects what Leaderboard ranks and how
+type LeaderboardOptions struct {
+	// Scope is a YYZZ season, or dbUtil.LeaderboardAllTime
+	Scope string
+	// RankBy is the ranking key, one of RankKeys
+	RankBy string
+	// TieBreakers are the keys compared, in order, when RankBy is equal.
+	// Users still tied share a rank.
+	TieBreakers []string
+	// Through, when set, ranks a season board as it stood after that week
+	// rather than after the newest scored week. All-time boards ignore it.
+	Through int
+}
+
+// CheckRankKeys returns an error if any of keys is not one of RankKeys
+func CheckRankKeys(keys ...string) error {
+	for _, key := range keys {
+		known := false
+		for _, k := range RankKeys {
+			known = known || key == k
+		}
+		if !known {
+			return fmt.Errorf("unknown ranking key %q (use %s)", key, strings.Join(RankKeys, ", "))
+		}
+	}
+	return nil
+}
+
+// ParseTieBreakers splits a comma separated list of ranking keys
+func ParseTieBreakers(list string) ([]string, error) {
+	var keys []string
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, CheckRankKeys(keys...)
+}
+
+// LeaderboardCmd represents the leaderboard command
+var LeaderboardCmd = &cobra.Command{
+	Use:   "leaderboard",
+	Short: "Rank users for a season or all time",
+	Long: `League Leaderboard
+			Rank users by weeks won, correct picks, pick percentage or perfect weeks,
+			with rank movement since the previous week and the gap to the leader,
+			and store the board in pickemcli_leaderboard`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope, _ := cmd.Flags().GetString("season")
+		opts, err := leaderboardOptions(scope)
+		if err != nil {
+			return err
+		}
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		store, done, err := openStore(ctx, database)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		if opts.Scope, err = resolveScope(ctx, store, opts.Scope); err != nil {
+			return err
+		}
+
+		entries, err := Leaderboard(ctx, store, opts)
+		if err != nil {
+			return err
+		}
+		if err := store.ReplaceLeaderboard(ctx, opts.Scope, entries); err != nil {
+			return err
+		}
+		return output.Write(cmd.OutOrStdout(), output.Format(), leaderboardTable(entries))
+	},
+}
+
+func init() {
+	LeaderboardCmd.Flags().String("season", "", `Season to rank (YYZZ), or "all" for all time (default: the current season)`)
+	LeaderboardCmd.Flags().String("by", "", "Rank by weeks_won, correct_picks, pick_percent or perfect_weeks (default: leaderboard.rank_by)")
+	LeaderboardCmd.Flags().String("tie-breakers", "", "Comma separated ranking keys to break ties with, in order (default: leaderboard.tie_breakers)")
+	if err := viper.BindPFlag("leaderboard.rank_by", LeaderboardCmd.Flags().Lookup("by")); err != nil {
+		panic(err.Error())
+	}
+	if err := viper.BindPFlag("leaderboard.tie_breakers", LeaderboardCmd.Flags().Lookup("tie-breakers")); err != nil {
+		panic(err.Error())
+	}
+	config.RegisterCheck("leaderboard.rank_by", func(key string) error { return CheckRankKeys(key) })
+	config.RegisterCheck("leaderboard.tie_breakers", func(list string) error {
+		_, err := ParseTieBreakers(list)
+		return err
+	})
+}
+
+// leaderboardOptions builds the options from scope (a season code, "all"
+// or "" for the current season) and the leaderboard settings
+func leaderboardOptions(scope string) (LeaderboardOptions, error) {
+	opts := LeaderboardOptions{RankBy: viper.GetString("leaderboard.rank_by")}
+	if err := CheckRankKeys(opts.RankBy); err != nil {
+		return opts, fmt.Errorf("leaderboard.rank_by: %w", err)
+	}
+	tieBreakers, err := ParseTieBreakers(viper.GetString("leaderboard.tie_breakers"))
+	if err != nil {
+		return opts, fmt.Errorf("leaderboard.tie_breakers: %w", err)
+	}
+	opts.TieBreakers = tieBreakers
+
+	opts.Scope, err = parseScope(scope, dbUtil.LeaderboardAllTime)
+	return opts, err
+}
+
+// UpdateLeaderboards stores the current season's and the all-time
+// leaderboards, ranked by the leaderboard settings
+func UpdateLeaderboards(ctx context.Context, store dbUtil.Store, current season.Season) error {
+	for _, scope := range []string{current.String(), dbUtil.LeaderboardAllTime} {
+		opts, err := leaderboardOptions(scope)
+		if err != nil {
+			return err
+		}
+		entries, err := Leaderboard(ctx, store, opts)
+		if err != nil {
+			return err
+		}
+		if err := store.ReplaceLeaderboard(ctx, scope, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// standing is one user's totals on a leaderboard
+type standing struct {
+	uid                                    string
+	weeksWon, correct, total, perfectWeeks int
+}
+
+// value returns the standing's value for a ranking key
+func (s *standing) value(key string) float64 {
+	switch key {
+	case RankWeeksWon:
+		return float64(s.weeksWon)
+	case RankCorrectPicks:
+		return float64(s.correct)
+	case RankPickPercent:
+		if s.total == 0 {
+			return 0
+		}
+		return float64(s.correct) / float64(s.total) * 100
+	case RankPerfectWeeks:
+		return float64(s.perfectWeeks)
+	}
+	return 0
+}
+
+// Leaderboard ranks every user with a pick in opts.Scope, counting weeks
+// up to the newest week with a scored game, or opts.Through. Movement compares each rank
+// with the board as it stood one week earlier.
+func Leaderboard(ctx context.Context, store dbUtil.Store, opts LeaderboardOptions) ([]dbUtil.LeaderboardEntry, error) {
+	if err := CheckRankKeys(append([]string{opts.RankBy}, opts.TieBreakers...)...); err != nil {
+		return nil, err
+	}
+
+	seasonFilter := opts.Scope
+	if opts.Scope == dbUtil.LeaderboardAllTime {
+		seasonFilter = ""
+	}
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonFilter})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonFilter, ScoredOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error getting scored games: %w", err)
+	}
+	points, err := store.SeasonPoints(ctx, seasonFilter)
+	if err != nil {
+		return nil, fmt.Errorf("error getting season points: %w", err)
+	}
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
+	}
+
+	latest := latestScoredWeek(games)
+	if opts.Through > 0 && opts.Scope != dbUtil.LeaderboardAllTime {
+		latest = seasonWeek{opts.Scope, opts.Through}
+	}
+	entries := rankStandings(standingsThrough(picks, games, points, latest), opts)
+
+	// Movement against the board as it stood a week earlier. Within a
+	// single season there is nothing to compare the first week with.
+	if latest.week > 1 || (opts.Scope == dbUtil.LeaderboardAllTime && latest.week == 1) {
+		previous := rankStandings(standingsThrough(picks, games, points, seasonWeek{latest.season, latest.week - 1}), opts)
+		previousRank := make(map[string]int, len(previous))
+		for _, e := range previous {
+			previousRank[e.UserID] = e.Rank
+		}
+		for i := range entries {
+			if rank, ok := previousRank[entries[i].UserID]; ok {
+				entries[i].Movement = dbUtil.IntPtr(rank - entries[i].Rank)
+			}
+		}
+	}
+
+	for i := range entries {
+		entries[i].Scope = opts.Scope
+		entries[i].UserEmail = dbUtil.EmailFor(emails, entries[i].UserID)
+		entries[i].Week = latest.week
+	}
+	return entries, nil
+}
+
+// latestScoredWeek returns the newest week with a scored game, in the
+// newest season with one
+func latestScoredWeek(games []dbUtil.Game) seasonWeek {
+	var latest seasonWeek
+	var latestSeason season.Season
+	for _, g := range games {
+		s, err := season.Parse(g.Season)
+		if err != nil {
+			continue
+		}
+		switch {
+		case latest.season == "", latestSeason.Before(s):
+			latest, latestSeason = seasonWeek{g.Season, g.Week}, s
+		case g.Season == latest.season && g.Week > latest.week:
+			latest.week = g.Week
+		}
+	}
+	return latest
+}
+
+// standingsThrough totals every user's picks, week wins and perfect weeks
+// up to and including cutoff. Weeks of seasons other than cutoff's are
+// always counted, since cutoff is in the newest season.
+func standingsThrough(picks []dbUtil.Pick, scoredGames []dbUtil.Game, points []dbUtil.SeasonPoints, cutoff seasonWeek) []*standing {
+	counts := func(sw seasonWeek) bool {
+		return sw.season != cutoff.season || sw.week <= cutoff.week
+	}
+
+	byUID := make(map[string]*standing)
+	for _, p := range picks {
+		if p.Season == "" || !counts(seasonWeek{p.Season, p.Week}) {
+			continue
+		}
+		s, ok := byUID[p.UID]
+		if !ok {
+			s = &standing{uid: p.UID}
+			byUID[p.UID] = s
+		}
+		s.total++
+		if p.Correct {
+			s.correct++
+		}
+	}
+
+	for _, sp := range points {
+		s, ok := byUID[sp.UserID]
+		if !ok {
+			continue
+		}
+		for i, won := range sp.WeekWinner {
+			if won && counts(seasonWeek{sp.Season, i + 1}) {
+				s.weeksWon++
+			}
+		}
+	}
+
+	scoredPerWeek := make(map[seasonWeek]int)
+	for _, g := range scoredGames {
+		if counts(seasonWeek{g.Season, g.Week}) {
+			scoredPerWeek[seasonWeek{g.Season, g.Week}]++
+		}
+	}
+	picksPerWeek := picksByWeek(picks)
+	for uid, s := range byUID {
+		for key, scored := range scoredPerWeek {
+			if picksPerWeek[uid][key].perfect(scored) {
+				s.perfectWeeks++
+			}
+		}
+	}
+
+	standings := make([]*standing, 0, len(byUID))
+	for _, uid := range sortedKeys(byUID) {
+		standings = append(standings, byUID[uid])
+	}
+	return standings
+}
+
+// rankStandings orders standings by opts.RankBy and then each tie-breaker,
+// highest first. Users equal on all of them share a rank (1, 2, 2, 4) and
+// are listed by user ID.
+func rankStandings(standings []*standing, opts LeaderboardOptions) []dbUtil.LeaderboardEntry {
+	keys := []string{opts.RankBy}
+	for _, key := range opts.TieBreakers {
+		if key != opts.RankBy {
+			keys = append(keys, key)
+		}
+	}
+	compare := func(a, b *standing) int {
+		for _, key := range keys {
+			if va, vb := a.value(key), b.value(key); va != vb {
+				if va > vb {
+					return -1
+				}
+				return 1
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		if c := compare(standings[i], standings[j]); c != 0 {
+			return c < 0
+		}
+		return standings[i].uid < standings[j].uid
+	})
+
+	entries := make([]dbUtil.LeaderboardEntry, len(standings))
+	for i, s := range standings {
+		rank := i + 1
+		if i > 0 && compare(standings[i-1], s) == 0 {
+			rank = entries[i-1].Rank
+		}
+		entries[i] = dbUtil.LeaderboardEntry{
+			UserID:       s.uid,
+			Rank:         rank,
+			Gap:          standings[0].value(opts.RankBy) - s.value(opts.RankBy),
+			RankedBy:     opts.RankBy,
+			WeeksWon:     s.weeksWon,
+			CorrectPicks: s.correct,
+			TotalPicks:   s.total,
+			PickPercent:  pickPercent(s.correct, s.total),
+			PerfectWeeks: s.perfectWeeks,
+		}
+	}
+	return entries
+}
+
+// leaderboardTable lays out entries for output.Write
+func leaderboardTable(entries []dbUtil.LeaderboardEntry) output.Table {
+	t := output.Table{Columns: []string{
+		"rank", "movement", "userID", "userEmail", "weeksWon", "correctPicks",
+		"totalPicks", "pickPercent", "perfectWeeks", "gap",
+	}}
+	for _, e := range entries {
+		var movement interface{}
+		if e.Movement != nil {
+			movement = *e.Movement
+		}
+		t.Append(e.Rank, movement, e.UserID, e.UserEmail, e.WeeksWon, e.CorrectPicks,
+			e.TotalPicks, e.PickPercent, e.PerfectWeeks, e.Gap)
+	}
+	return t
+}
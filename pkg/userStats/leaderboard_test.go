@@ -0,0 +1,198 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// place is a LeaderboardEntry reduced to what the tests compare. Movement
+// is nil when there is nothing to compare with.
+type place struct {
+	UserID   string
+	Rank     int
+	Movement interface{}
+	Gap      float64
+}
+
+func places(entries []dbUtil.LeaderboardEntry) []place {
+	var out []place
+	for _, e := range entries {
+		var movement interface{}
+		if e.Movement != nil {
+			movement = *e.Movement
+		}
+		out = append(out, place{e.UserID, e.Rank, movement, e.Gap})
+	}
+	return out
+}
+
+func TestRankStandings(t *testing.T) {
+	// a, b and c have won two weeks each; a and b are level on correct
+	// picks, b with the better pick percentage
+	standings := func() []*standing {
+		return []*standing{
+			{uid: "a", weeksWon: 2, correct: 5, total: 10},
+			{uid: "b", weeksWon: 2, correct: 5, total: 8, perfectWeeks: 1},
+			{uid: "c", weeksWon: 2, correct: 6, total: 12},
+			{uid: "d", weeksWon: 1, correct: 9, total: 9, perfectWeeks: 2},
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts LeaderboardOptions
+		want []place
+	}{
+		{
+			name: "correct picks then pick percent",
+			opts: LeaderboardOptions{RankBy: RankWeeksWon, TieBreakers: []string{RankCorrectPicks, RankPickPercent}},
+			want: []place{{"c", 1, nil, 0}, {"b", 2, nil, 0}, {"a", 3, nil, 0}, {"d", 4, nil, 1}},
+		},
+		{
+			name: "pick percent then correct picks",
+			opts: LeaderboardOptions{RankBy: RankWeeksWon, TieBreakers: []string{RankPickPercent, RankCorrectPicks}},
+			want: []place{{"b", 1, nil, 0}, {"c", 2, nil, 0}, {"a", 3, nil, 0}, {"d", 4, nil, 1}},
+		},
+		{
+			name: "full ties share a rank",
+			opts: LeaderboardOptions{RankBy: RankWeeksWon},
+			want: []place{{"a", 1, nil, 0}, {"b", 1, nil, 0}, {"c", 1, nil, 0}, {"d", 4, nil, 1}},
+		},
+		{
+			name: "rank key repeated as a tie-breaker",
+			opts: LeaderboardOptions{RankBy: RankCorrectPicks, TieBreakers: []string{RankCorrectPicks}},
+			want: []place{{"d", 1, nil, 0}, {"c", 2, nil, 3}, {"a", 3, nil, 4}, {"b", 3, nil, 4}},
+		},
+		{
+			name: "pick percent gap",
+			opts: LeaderboardOptions{RankBy: RankPickPercent},
+			want: []place{{"d", 1, nil, 0}, {"b", 2, nil, 37.5}, {"a", 3, nil, 50}, {"c", 3, nil, 50}},
+		},
+		{
+			name: "perfect weeks",
+			opts: LeaderboardOptions{RankBy: RankPerfectWeeks, TieBreakers: []string{RankCorrectPicks}},
+			want: []place{{"d", 1, nil, 0}, {"b", 2, nil, 1}, {"c", 3, nil, 2}, {"a", 4, nil, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := places(rankStandings(standings(), tt.opts))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankStandings\n got: %+v\nwant: %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// leaderboardStore returns a MemoryStore for season 2425 with three scored
+// weeks, each won by a different user, after a 2324 season only user 1
+// played
+func leaderboardStore() *dbUtil.MemoryStore {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2324", Week: 1, Scored: true},
+		{ID: "10", Season: "2425", Week: 1, Scored: true},
+		{ID: "11", Season: "2425", Week: 1, Scored: true},
+		{ID: "12", Season: "2425", Week: 2, Scored: true},
+		{ID: "13", Season: "2425", Week: 3, Scored: true},
+		{ID: "14", Season: "2425", Week: 4},
+	}
+	pick := func(uid, season, gameID string, week int, correct bool) dbUtil.Pick {
+		return dbUtil.Pick{UID: uid, Season: season, Week: week, GameID: gameID, Correct: correct, Graded: true}
+	}
+	store.PickRows = []dbUtil.Pick{
+		pick("1", "2324", "1", 1, true),
+		pick("1", "2425", "10", 1, true), pick("1", "2425", "11", 1, true),
+		pick("1", "2425", "12", 2, false), pick("1", "2425", "13", 3, false),
+		pick("2", "2425", "10", 1, true), pick("2", "2425", "11", 1, false),
+		pick("2", "2425", "12", 2, true), pick("2", "2425", "13", 3, true),
+		pick("3", "2425", "10", 1, false), pick("3", "2425", "11", 1, false),
+		pick("3", "2425", "12", 2, true), pick("3", "2425", "13", 3, true),
+	}
+	store.SeasonPointsRows = []dbUtil.SeasonPoints{
+		{UserID: "1", Season: "2324", WeekWinner: weeksWon(1)},
+		{UserID: "1", Season: "2425", WeekWinner: weeksWon(1)},
+		{UserID: "2", Season: "2425", WeekWinner: weeksWon(2)},
+		{UserID: "3", Season: "2425", WeekWinner: weeksWon(3)},
+	}
+	store.Emails["1"] = "one@example.com"
+	store.Emails["2"] = "two@example.com"
+	store.Emails["3"] = "three@example.com"
+	return store
+}
+
+func TestLeaderboard(t *testing.T) {
+	rankBy := func(scope string, through int) LeaderboardOptions {
+		return LeaderboardOptions{Scope: scope, RankBy: RankCorrectPicks, TieBreakers: []string{RankWeeksWon}, Through: through}
+	}
+	tests := []struct {
+		name string
+		opts LeaderboardOptions
+		week int
+		want []place
+	}{
+		{
+			// After week 2, 1 and 2 were level on both keys and 3 third
+			name: "latest week",
+			opts: rankBy("2425", 0),
+			week: 3,
+			want: []place{{"2", 1, 0, 0}, {"1", 2, -1, 1}, {"3", 2, 1, 1}},
+		},
+		{
+			name: "through week 2",
+			opts: rankBy("2425", 2),
+			week: 2,
+			want: []place{{"1", 1, 0, 0}, {"2", 1, 1, 0}, {"3", 3, 0, 1}},
+		},
+		{
+			name: "through week 1",
+			opts: rankBy("2425", 1),
+			week: 1,
+			want: []place{{"1", 1, nil, 0}, {"2", 2, nil, 1}, {"3", 3, nil, 2}},
+		},
+		{
+			// 2324 counts in full and puts 1 ahead of 2 on weeks won.
+			// Through only applies to season boards.
+			name: "all time",
+			opts: rankBy(dbUtil.LeaderboardAllTime, 1),
+			week: 3,
+			want: []place{{"1", 1, 0, 0}, {"2", 2, 0, 0}, {"3", 3, 0, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Leaderboard(context.Background(), leaderboardStore(), tt.opts)
+			if err != nil {
+				t.Fatalf("Leaderboard: %v", err)
+			}
+			if got := places(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Leaderboard\n got: %+v\nwant: %+v", got, tt.want)
+			}
+			for _, e := range entries {
+				if e.Scope != tt.opts.Scope || e.Week != tt.week || e.RankedBy != RankCorrectPicks {
+					t.Errorf("userID %s: scope %q, week %d, rankedBy %q; want %q, %d, %q",
+						e.UserID, e.Scope, e.Week, e.RankedBy, tt.opts.Scope, tt.week, RankCorrectPicks)
+				}
+			}
+		})
+	}
+}
+
+func TestLeaderboardTotals(t *testing.T) {
+	entries, err := Leaderboard(context.Background(), leaderboardStore(), LeaderboardOptions{Scope: "2425", RankBy: RankWeeksWon})
+	if err != nil {
+		t.Fatalf("Leaderboard: %v", err)
+	}
+	want := dbUtil.LeaderboardEntry{
+		Scope: "2425", UserID: "1", UserEmail: "one@example.com", Rank: 1, Movement: dbUtil.IntPtr(0),
+		RankedBy: RankWeeksWon, WeeksWon: 1, CorrectPicks: 2, TotalPicks: 4, PickPercent: 50, PerfectWeeks: 1, Week: 3,
+	}
+	if !reflect.DeepEqual(entries[0], want) {
+		t.Errorf("entries[0]\n got: %+v\nwant: %+v", entries[0], want)
+	}
+}
@@ -1,14 +1,13 @@
 package userStats
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"log"
 
-	"github.com/jimdaga/pickemcli/internal/db"
 	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // LeastPicked represents the leastPicked command
@@ -17,166 +16,66 @@ var LeastPicked = &cobra.Command{
 	Short: "Generate least pick analytics",
 	Long: `least Picks Data Generation
 			Generate various analytics based on users least picks`,
-	Run: func(cmd *cobra.Command, args []string) {
-		database := db.Connect()
-		defer database.Close()
-
-		RunLeastPicked(database)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCommand(cmd, RunLeastPicked)
 	},
 }
 
-// RunLeastPicked executes the least picked teams analysis
-func RunLeastPicked(db *sql.DB) {
-	fmt.Println("..| Least Picked Team(s) by UID |..")
-	LeastPickedByUid(db)
+func init() {
+	addSeasonFlags(LeastPicked)
 }
 
-func LeastPickedByUid(db *sql.DB) {
-	currentSeason := viper.GetString("app.season.current")
+// RunLeastPicked executes the least picked teams analysis
+func RunLeastPicked(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error) {
+	log.Println("..| Least Picked Team(s) by UID |..")
+	return LeastPickedByUid(ctx, store, current)
+}
 
-	uidrows, err := db.Query("SELECT DISTINCT(uid) FROM public.pickem_api_gamepicks")
+// LeastPickedByUid finds each user's least picked team(s), all time and for
+// the current season
+func LeastPickedByUid(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error) {
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{})
 	if err != nil {
-		log.Printf("Error getting distinct UIDs: %v", err)
-		return
+		return nil, fmt.Errorf("error getting picks: %w", err)
 	}
-	defer uidrows.Close()
-
-	uids := make([]string, 0)
-	for uidrows.Next() {
-		var uid string
-		if err := uidrows.Scan(&uid); err != nil {
-			log.Printf("Error scanning UID: %v", err)
-			continue
-		}
-		uids = append(uids, uid)
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
 	}
 
-	for _, uid := range uids {
-		// Get user email
-		userEmail, err := dbUtil.GetUserEmail(db, uid)
-		if err != nil {
-			log.Printf("Error getting email for UID %s: %v", uid, err)
-			continue
-		}
-
-		// Create user stats object
-		stats := dbUtil.NewUserStats(uid, userEmail)
+	allStats := leastPickedStats(picks, emails, current.String())
 
-		// Find the least picked team for all time
-		allTimeRows, err := db.Query("SELECT uid, pick, COUNT(*) as count "+
-			"FROM pickem_api_gamepicks "+
-			"WHERE uid = $1 "+
-			"GROUP BY uid, pick "+
-			"HAVING COUNT(*) = (SELECT MIN(c) FROM (SELECT COUNT(*) as c FROM pickem_api_gamepicks WHERE uid = $1 GROUP BY uid, pick) subquery) "+
-			"ORDER BY count ASC", uid)
-		if err != nil {
-			log.Printf("Error getting all-time least picked for UID %s: %v", uid, err)
-			continue
-		}
-
-		// Collect all the least picked teams (handle ties)
-		var leastPickedTeams []string
-		var minCount int
-		for allTimeRows.Next() {
-			var uidResult string
-			var pick string
-			var count int
-			err := allTimeRows.Scan(&uidResult, &pick, &count)
-			if err != nil {
-				log.Printf("Error scanning all-time result for UID %s: %v", uid, err)
-				continue
-			}
-			leastPickedTeams = append(leastPickedTeams, pick)
-			minCount = count
-		}
-		allTimeRows.Close()
-
-		// Set the least picked team(s) for all time
-		if len(leastPickedTeams) > 0 {
-			var leastPickedString string
-			if len(leastPickedTeams) == 1 {
-				leastPickedString = leastPickedTeams[0]
-			} else {
-				// Handle ties by joining team names
-				leastPickedString = ""
-				for i, team := range leastPickedTeams {
-					if i > 0 {
-						leastPickedString += ", "
-					}
-					leastPickedString += team
-				}
-			}
-			stats.LeastPickedTotal = dbUtil.StringPtr(leastPickedString)
-		}
+	// Upsert the user stats
+	if err := store.UpsertUserStats(ctx, allStats); err != nil {
+		return nil, fmt.Errorf("error upserting user stats: %w", err)
+	}
+	return allStats, nil
+}
 
-		// Find the least picked team for current season
-		seasonRows, err := db.Query("SELECT uid, pick, COUNT(*) as count "+
-			"FROM pickem_api_gamepicks "+
-			"WHERE uid = $1 AND gameseason = $2 "+
-			"GROUP BY uid, pick "+
-			"HAVING COUNT(*) = (SELECT MIN(c) FROM (SELECT COUNT(*) as c FROM pickem_api_gamepicks WHERE uid = $1 AND gameseason = $2 GROUP BY uid, pick) subquery) "+
-			"ORDER BY count ASC", uid, currentSeason)
+// leastPickedStats sets LeastPickedTotal and LeastPickedSeason for every
+// user with a pick. Only teams the user has picked at least once are
+// considered. Ties are joined with ", ".
+func leastPickedStats(picks []dbUtil.Pick, emails map[string]string, season string) []*dbUtil.UserStats {
+	total, seasonal := teamPickCounts(picks, season)
 
-		if err != nil {
-			log.Printf("Error getting season least picked for UID %s: %v", uid, err)
-			// Continue with just all-time data
-		} else {
-			// Collect season least picked teams (handle ties)
-			var seasonLeastPickedTeams []string
-			var seasonMinCount int
-			for seasonRows.Next() {
-				var uidResult string
-				var pick string
-				var count int
-				err := seasonRows.Scan(&uidResult, &pick, &count)
-				if err != nil {
-					log.Printf("Error scanning season result for UID %s: %v", uid, err)
-					continue
-				}
-				seasonLeastPickedTeams = append(seasonLeastPickedTeams, pick)
-				seasonMinCount = count
-			}
-			seasonRows.Close()
+	var allStats []*dbUtil.UserStats
+	for _, uid := range sortedKeys(total) {
+		stats := dbUtil.NewUserStats(uid, dbUtil.EmailFor(emails, uid))
 
-			// Set the least picked team(s) for current season
-			if len(seasonLeastPickedTeams) > 0 {
-				var seasonLeastPickedString string
-				if len(seasonLeastPickedTeams) == 1 {
-					seasonLeastPickedString = seasonLeastPickedTeams[0]
-				} else {
-					// Handle ties by joining team names
-					seasonLeastPickedString = ""
-					for i, team := range seasonLeastPickedTeams {
-						if i > 0 {
-							seasonLeastPickedString += ", "
-						}
-						seasonLeastPickedString += team
-					}
-				}
-				stats.LeastPickedSeason = dbUtil.StringPtr(seasonLeastPickedString)
-			}
+		teams, minCount := pickedTeams(total[uid], false)
+		stats.LeastPickedTotal = dbUtil.StringPtr(teams)
 
-			log.Printf("✓ UID: %s, Least Picked - Total: %s (%d picks), Season: %s (%d picks)",
-				uid,
-				func() string {
-					if stats.LeastPickedTotal != nil {
-						return *stats.LeastPickedTotal
-					}
-					return "none"
-				}(),
-				minCount,
-				func() string {
-					if stats.LeastPickedSeason != nil {
-						return *stats.LeastPickedSeason
-					}
-					return "none"
-				}(),
-				seasonMinCount)
+		var seasonMinCount int
+		if counts, ok := seasonal[uid]; ok {
+			var seasonTeams string
+			seasonTeams, seasonMinCount = pickedTeams(counts, false)
+			stats.LeastPickedSeason = dbUtil.StringPtr(seasonTeams)
 		}
 
-		// Upsert the user stats
-		if err := dbUtil.UpsertUserStats(db, stats); err != nil {
-			log.Printf("Error upserting user stats for UID %s: %v", uid, err)
-		}
+		log.Printf("✓ UID: %s, Least Picked - Total: %s (%d picks), Season: %s (%d picks)",
+			uid, teams, minCount, stringOrNone(stats.LeastPickedSeason), seasonMinCount)
+
+		allStats = append(allStats, stats)
 	}
+	return allStats
 }
@@ -1,14 +1,15 @@
 package userStats
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/jimdaga/pickemcli/internal/db"
 	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // PickStats represents the pickStats command
@@ -17,388 +18,239 @@ var PickStats = &cobra.Command{
 	Short: "Generate pick analytics",
 	Long: `Picks Data Generation
 			Generate various analytics based on users picks`,
-	Run: func(cmd *cobra.Command, args []string) {
-		database := db.Connect()
-		defer database.Close()
-
-		RunPickStats(database)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCommand(cmd, RunPickStats)
 	},
 }
 
-// RunPickStats executes the pick statistics analysis
-func RunPickStats(db *sql.DB) {
-	fmt.Println("..| Correct Picks by UID |..")
-	CorrectPicksByUid(db)
-	fmt.Println("..| Weeks Won by UID |..")
-	WeeksWonByUid(db)
+func init() {
+	addSeasonFlags(PickStats)
 }
 
-func CorrectPicksByUid(db *sql.DB) {
-	currentSeason := viper.GetString("app.season.current")
+// RunPickStats executes the pick statistics analysis
+func RunPickStats(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error) {
+	log.Println("..| Correct Picks by UID |..")
+	correct, err := CorrectPicksByUid(ctx, store, current)
+	if db.IsConnectionError(err) {
+		return nil, err
+	}
+	log.Println("..| Weeks Won by UID |..")
+	weeks, weeksErr := WeeksWonByUid(ctx, store, current)
+	return mergeResults(correct, weeks), errors.Join(err, weeksErr)
+}
 
-	uidrows, err := db.Query("SELECT DISTINCT(uid) FROM public.pickem_api_gamepicks WHERE gameseason IS NOT NULL")
+// CorrectPicksByUid calculates every user's pick accuracy, all time and
+// for the current season, from one grouped count of the picks table, and
+// returns the records it wrote
+func CorrectPicksByUid(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error) {
+	totals, err := store.PickTotals(ctx, current.String())
 	if err != nil {
-		log.Printf("Error getting distinct UIDs: %v", err)
-		return
+		return nil, fmt.Errorf("error getting pick totals: %w", err)
 	}
-	defer uidrows.Close()
-
-	uids := make([]string, 0)
-	for uidrows.Next() {
-		var uid string
-		if err := uidrows.Scan(&uid); err != nil {
-			log.Printf("Error scanning UID: %v", err)
-			continue
-		}
-		uids = append(uids, uid)
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
 	}
 
-	// Process each user
-	for _, uid := range uids {
-		// Get user email
-		userEmail, err := dbUtil.GetUserEmail(db, uid)
-		if err != nil {
-			log.Printf("Error getting email for UID %s: %v", uid, err)
-			continue
-		}
-
-		// Create user stats object
-		stats := dbUtil.NewUserStats(uid, userEmail)
+	allStats := correctPickStats(totals, emails)
 
-		// Calculate ALL TIME stats
-		var correctPicksTotal, totalPicksTotal int
-		err = db.QueryRow("SELECT count(*) FROM pickem_api_gamepicks "+
-			"WHERE uid = $1 AND pick_correct = true AND gameseason IS NOT NULL", uid).Scan(&correctPicksTotal)
-		if err != nil {
-			log.Printf("Error getting total correct picks for UID %s: %v", uid, err)
-			continue
-		}
+	// Upsert the user stats
+	if err := store.UpsertUserStats(ctx, allStats); err != nil {
+		return nil, fmt.Errorf("error upserting user stats: %w", err)
+	}
 
-		err = db.QueryRow("SELECT count(*) FROM pickem_api_gamepicks "+
-			"WHERE uid = $1 AND gameseason IS NOT NULL", uid).Scan(&totalPicksTotal)
-		if err != nil {
-			log.Printf("Error getting total picks for UID %s: %v", uid, err)
-			continue
-		}
+	for _, stats := range allStats {
+		log.Printf("✓ UID: %s, Total: %d/%d (%d%%), Season: %d/%d (%d%%)",
+			stats.UserID, *stats.CorrectPickTotalTotal, *stats.TotalPicksTotal, *stats.PickPercentTotal,
+			*stats.CorrectPickTotalSeason, *stats.TotalPicksSeason, *stats.PickPercentSeason)
+	}
+	return allStats, nil
+}
 
-		var percentTotal int
-		if totalPicksTotal > 0 {
-			percentTotal = int(float64(correctPicksTotal) / float64(totalPicksTotal) * 100)
-		}
+// correctPickStats sets the correct pick, total pick and pick percentage
+// fields, all time and for the season, from each user's pick totals
+func correctPickStats(totals []dbUtil.PickTotals, emails map[string]string) []*dbUtil.UserStats {
+	var allStats []*dbUtil.UserStats
+	for _, t := range totals {
+		stats := dbUtil.NewUserStats(t.UID, dbUtil.EmailFor(emails, t.UID))
 
 		// Set all-time stats
-		stats.CorrectPickTotalTotal = dbUtil.IntPtr(correctPicksTotal)
-		stats.TotalPicksTotal = dbUtil.IntPtr(totalPicksTotal)
-		stats.PickPercentTotal = dbUtil.IntPtr(percentTotal)
+		stats.CorrectPickTotalTotal = dbUtil.IntPtr(t.Correct)
+		stats.TotalPicksTotal = dbUtil.IntPtr(t.Picks)
+		stats.PickPercentTotal = dbUtil.IntPtr(pickPercent(t.Correct, t.Picks))
 
-		// Calculate CURRENT SEASON stats
-		var correctPicksSeason, totalPicksSeason int
-		err = db.QueryRow("SELECT count(*) FROM pickem_api_gamepicks "+
-			"WHERE uid = $1 AND pick_correct = true AND gameseason = $2 AND gameseason IS NOT NULL", uid, currentSeason).Scan(&correctPicksSeason)
-		if err != nil {
-			log.Printf("Error getting season correct picks for UID %s: %v", uid, err)
-			// Continue with just total stats
-		} else {
-			err = db.QueryRow("SELECT count(*) FROM pickem_api_gamepicks "+
-				"WHERE uid = $1 AND gameseason = $2 AND gameseason IS NOT NULL", uid, currentSeason).Scan(&totalPicksSeason)
-			if err != nil {
-				log.Printf("Error getting season picks for UID %s: %v", uid, err)
-			} else {
-				var percentSeason int
-				if totalPicksSeason > 0 {
-					percentSeason = int(float64(correctPicksSeason) / float64(totalPicksSeason) * 100)
-				}
+		// Set season stats
+		stats.CorrectPickTotalSeason = dbUtil.IntPtr(t.SeasonCorrect)
+		stats.TotalPicksSeason = dbUtil.IntPtr(t.SeasonPicks)
+		stats.PickPercentSeason = dbUtil.IntPtr(pickPercent(t.SeasonCorrect, t.SeasonPicks))
 
-				// Set season stats
-				stats.CorrectPickTotalSeason = dbUtil.IntPtr(correctPicksSeason)
-				stats.TotalPicksSeason = dbUtil.IntPtr(totalPicksSeason)
-				stats.PickPercentSeason = dbUtil.IntPtr(percentSeason)
-			}
-		}
+		allStats = append(allStats, stats)
+	}
+	return allStats
+}
 
-		// Upsert the user stats
-		if err := dbUtil.UpsertUserStats(db, stats); err != nil {
-			log.Printf("Error upserting user stats for UID %s: %v", uid, err)
-		} else {
-			log.Printf("✓ UID: %s, Total: %d/%d (%d%%), Season: %d/%d (%d%%)",
-				uid, correctPicksTotal, totalPicksTotal, percentTotal,
-				correctPicksSeason, totalPicksSeason,
-				func() int {
-					if totalPicksSeason > 0 {
-						return int(float64(correctPicksSeason) / float64(totalPicksSeason) * 100)
-					}
-					return 0
-				}())
-		}
+// pickPercent returns correct as a whole-number percentage of total,
+// truncated the same way the site displays it
+func pickPercent(correct, total int) int {
+	if total == 0 {
+		return 0
 	}
+	return int(float64(correct) / float64(total) * 100)
 }
 
-func WeeksWonByUid(db *sql.DB) {
-	uidrows, err := db.Query("SELECT DISTINCT(uid) FROM public.pickem_api_gamepicks WHERE gameseason IS NOT NULL")
+// WeeksWonByUid calculates weeks won, seasons won, missed picks and perfect
+// weeks for every user, all time and for the current season, and returns
+// the records it wrote
+func WeeksWonByUid(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error) {
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{})
 	if err != nil {
-		log.Printf("Error getting distinct UIDs: %v", err)
-		return
+		return nil, fmt.Errorf("error getting picks: %w", err)
 	}
-	defer uidrows.Close()
-
-	uids := make([]string, 0)
-	for uidrows.Next() {
-		var uid string
-		if err := uidrows.Scan(&uid); err != nil {
-			log.Printf("Error scanning UID: %v", err)
-			continue
-		}
-		uids = append(uids, uid)
+	games, err := store.Games(ctx, dbUtil.GameFilter{ScoredOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error getting scored games: %w", err)
+	}
+	points, err := store.SeasonPoints(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("error getting season points: %w", err)
+	}
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
 	}
 
-	// Process each user
-	for _, uid := range uids {
-		// Get user email
-		userEmail, err := dbUtil.GetUserEmail(db, uid)
-		if err != nil {
-			log.Printf("Error getting email for UID %s: %v", uid, err)
-			continue
-		}
-
-		// Create user stats object
-		stats := dbUtil.NewUserStats(uid, userEmail)
+	allStats := weeksWonStats(picks, games, points, emails, current.String())
+	for _, stats := range allStats {
+		log.Printf("✓ UID: %s | Weeks Won: %d/%d | Seasons Won: %d | Missed Picks: %d/%d | Perfect Weeks: %d/%d",
+			stats.UserID, *stats.WeeksWonSeason, *stats.WeeksWonTotal, *stats.SeasonsWon,
+			*stats.MissedPicksSeason, *stats.MissedPicksTotal, *stats.PerfectWeeksSeason, *stats.PerfectWeeksTotal)
+	}
 
-		// Calculate weeks won - all time
-		var userID string
-		var weeksWonTotal int
-		err = db.QueryRow("SELECT \"userID\","+
-			"COALESCE(SUM("+
-			"CASE WHEN \"week_1_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_2_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_3_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_4_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_5_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_6_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_7_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_8_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_9_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_10_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_11_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_12_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_13_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_14_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_15_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_16_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_17_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_18_winner\" THEN 1 ELSE 0 END"+
-			"), 0) AS \"total_wins\""+
-			"FROM \"pickem_api_userseasonpoints\""+
-			"WHERE \"userID\" = $1 AND \"gameseason\" IS NOT NULL "+
-			"GROUP BY \"userID\"", uid).Scan(&userID, &weeksWonTotal)
+	// Upsert the user stats
+	if err := store.UpsertUserStats(ctx, allStats); err != nil {
+		return nil, fmt.Errorf("error upserting user stats: %w", err)
+	}
+	return allStats, nil
+}
 
-		if err != nil {
-			if err == sql.ErrNoRows {
-				// User has no records yet, set to 0
-				weeksWonTotal = 0
-				log.Printf("No season points record found for UID %s, setting weeks won to 0", uid)
-			} else {
-				log.Printf("Error getting total weeks won for UID %s: %v", uid, err)
-				continue
-			}
-		}
+// seasonWeek identifies one week of one season
+type seasonWeek struct {
+	season string
+	week   int
+}
 
-		// Set weeks won total
-		stats.WeeksWonTotal = dbUtil.IntPtr(weeksWonTotal)
+// weekPicks counts one user's picks in one week
+type weekPicks struct {
+	picks, correct int
+}
 
-		// Calculate current season weeks won
-		currentSeason := viper.GetString("app.season.current")
-		var weeksWonSeason int
-		err = db.QueryRow("SELECT "+
-			"COALESCE(SUM("+
-			"CASE WHEN \"week_1_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_2_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_3_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_4_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_5_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_6_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_7_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_8_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_9_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_10_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_11_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_12_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_13_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_14_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_15_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_16_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_17_winner\" THEN 1 ELSE 0 END +"+
-			"CASE WHEN \"week_18_winner\" THEN 1 ELSE 0 END"+
-			"), 0) AS \"season_wins\""+
-			"FROM \"pickem_api_userseasonpoints\""+
-			"WHERE \"userID\" = $1 AND \"gameseason\" = $2 AND \"gameseason\" IS NOT NULL", uid, currentSeason).Scan(&weeksWonSeason)
+// perfect reports whether the user picked every one of the week's scored
+// games and got every pick right. A nil weekPicks (no picks) is not perfect.
+func (wp *weekPicks) perfect(scored int) bool {
+	return wp != nil && wp.picks == scored && wp.correct == scored
+}
 
-		if err != nil {
-			log.Printf("Error getting season weeks won for UID %s: %v", uid, err)
-			weeksWonSeason = 0
+// picksByWeek counts every user's picks and correct picks per week. Picks
+// without a season are ignored.
+func picksByWeek(picks []dbUtil.Pick) map[string]map[seasonWeek]*weekPicks {
+	byUID := make(map[string]map[seasonWeek]*weekPicks)
+	for _, p := range picks {
+		if p.Season == "" {
+			continue
 		}
-
-		// Set season weeks won
-		stats.WeeksWonSeason = dbUtil.IntPtr(weeksWonSeason)
-
-		// Calculate seasons won (year_winner = true count)
-		var seasonsWon int
-		err = db.QueryRow("SELECT COUNT(*) FROM \"pickem_api_userseasonpoints\" WHERE \"userID\" = $1 AND \"year_winner\" = true AND \"gameseason\" IS NOT NULL", uid).Scan(&seasonsWon)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				seasonsWon = 0
-				log.Printf("No season winner records found for UID %s, setting seasons won to 0", uid)
-			} else {
-				log.Printf("Error getting seasons won for UID %s: %v", uid, err)
-				seasonsWon = 0 // Default to 0 on error
-			}
+		if byUID[p.UID] == nil {
+			byUID[p.UID] = make(map[seasonWeek]*weekPicks)
 		}
-
-		// Set seasons won
-		stats.SeasonsWon = dbUtil.IntPtr(seasonsWon)
-
-		// Calculate missed picks - season
-		var missedPicksSeason int
-
-		// Count scored games for current season that the user did NOT pick
-		err = db.QueryRow(`
-			SELECT COUNT(*) 
-			FROM "pickem_api_gamesandscores" gs
-			WHERE gs."gameseason" = $1 
-			AND gs."gameScored" = true
-			AND gs."gameseason" IS NOT NULL
-			AND NOT EXISTS (
-				SELECT 1 FROM "pickem_api_gamepicks" gp 
-				WHERE gp."pick_game_id" = gs."id" 
-				AND gp."userID" = $2
-				AND gp."gameseason" IS NOT NULL
-			)`, currentSeason, uid).Scan(&missedPicksSeason)
-
-		if err != nil {
-			log.Printf("Error getting missed picks for season %s, UID %s: %v", currentSeason, uid, err)
-			missedPicksSeason = 0
+		key := seasonWeek{p.Season, p.Week}
+		wp, ok := byUID[p.UID][key]
+		if !ok {
+			wp = &weekPicks{}
+			byUID[p.UID][key] = wp
 		}
-		stats.MissedPicksSeason = dbUtil.IntPtr(missedPicksSeason)
-
-		// Calculate missed picks - total (all time)
-		var missedPicksTotal int
+		wp.picks++
+		if p.Correct {
+			wp.correct++
+		}
+	}
+	return byUID
+}
 
-		// Count scored games across all seasons that the user did NOT pick
-		err = db.QueryRow(`
-			SELECT COUNT(*) 
-			FROM "pickem_api_gamesandscores" gs
-			WHERE gs."gameScored" = true
-			AND gs."gameseason" IS NOT NULL
-			AND NOT EXISTS (
-				SELECT 1 FROM "pickem_api_gamepicks" gp 
-				WHERE gp."pick_game_id" = gs."id" 
-				AND gp."uid" = $1
-				AND gp."gameseason" IS NOT NULL
-			)`, uid).Scan(&missedPicksTotal)
+// weeksWonStats derives the week based statistics for every user with a
+// pick in a season:
+//   - weeks won and seasons won, from the season points flags
+//   - missed picks, the scored games the user has no pick for
+//   - perfect weeks, weeks where the user picked every scored game and
+//     every one of their picks that week was correct
+func weeksWonStats(picks []dbUtil.Pick, scoredGames []dbUtil.Game, points []dbUtil.SeasonPoints, emails map[string]string, season string) []*dbUtil.UserStats {
+	// Scored games per week, and per season for the missed pick counts
+	scoredPerWeek := make(map[seasonWeek]int)
+	for _, g := range scoredGames {
+		scoredPerWeek[seasonWeek{g.Season, g.Week}]++
+	}
 
-		if err != nil {
-			log.Printf("Error getting total missed picks for UID %s: %v", uid, err)
-			missedPicksTotal = 0
+	pickedGames := make(map[string]map[string]bool)
+	for _, p := range picks {
+		if p.Season == "" {
+			continue
+		}
+		if pickedGames[p.UID] == nil {
+			pickedGames[p.UID] = make(map[string]bool)
 		}
-		stats.MissedPicksTotal = dbUtil.IntPtr(missedPicksTotal)
+		pickedGames[p.UID][p.GameID] = true
+	}
+	picksPerWeek := picksByWeek(picks)
 
-		// Calculate perfect weeks - season
-		var perfectWeeksSeason int
-		perfectWeeksQuery := `
-			SELECT COUNT(DISTINCT gs."gameWeek") 
-			FROM pickem_api_gamesandscores gs
-			WHERE gs.gameseason = $1 
-			AND gs."gameScored" = true
-			AND gs.gameseason IS NOT NULL
-			AND (
-				-- Count of scored games in this week
-				SELECT COUNT(*) FROM pickem_api_gamesandscores gs2 
-				WHERE gs2."gameWeek" = gs."gameWeek" 
-				AND gs2.gameseason = gs.gameseason 
-				AND gs2."gameScored" = true
-				AND gs2.gameseason IS NOT NULL
-			) = (
-				-- Count of correct picks by user in this week
-				SELECT COUNT(*) FROM pickem_api_gamepicks gp 
-				WHERE gp."gameWeek" = gs."gameWeek" 
-				AND gp.gameseason = gs.gameseason 
-				AND gp."uid" = $2 
-				AND gp.pick_correct = true
-				AND gp.gameseason IS NOT NULL
-			)
-			AND (
-				-- Ensure user made picks for ALL scored games (no missed picks)
-				SELECT COUNT(*) FROM pickem_api_gamesandscores gs3
-				WHERE gs3."gameWeek" = gs."gameWeek" 
-				AND gs3.gameseason = gs.gameseason 
-				AND gs3."gameScored" = true
-				AND gs3.gameseason IS NOT NULL
-			) = (
-				-- Count of total picks by user in this week
-				SELECT COUNT(*) FROM pickem_api_gamepicks gp2 
-				WHERE gp2."gameWeek" = gs."gameWeek" 
-				AND gp2.gameseason = gs.gameseason 
-				AND gp2."uid" = $2
-				AND gp2.gameseason IS NOT NULL
-			)`
+	var allStats []*dbUtil.UserStats
+	for _, uid := range sortedKeys(pickedGames) {
+		stats := dbUtil.NewUserStats(uid, dbUtil.EmailFor(emails, uid))
 
-		err = db.QueryRow(perfectWeeksQuery, currentSeason, uid).Scan(&perfectWeeksSeason)
-		if err != nil {
-			log.Printf("Error getting perfect weeks for season %s, UID %s: %v", currentSeason, uid, err)
-			perfectWeeksSeason = 0
+		// Weeks and seasons won
+		var weeksWonTotal, weeksWonSeason, seasonsWon int
+		for _, sp := range points {
+			if sp.UserID != uid {
+				continue
+			}
+			weeksWonTotal += sp.WeeksWon()
+			if sp.Season == season {
+				weeksWonSeason += sp.WeeksWon()
+			}
+			if sp.YearWinner {
+				seasonsWon++
+			}
 		}
-		stats.PerfectWeeksSeason = dbUtil.IntPtr(perfectWeeksSeason)
-
-		// Calculate perfect weeks - total (all time)
-		var perfectWeeksTotal int
-		perfectWeeksTotalQuery := `
-			SELECT COUNT(DISTINCT gs.gameseason || '-' || gs."gameWeek") 
-			FROM pickem_api_gamesandscores gs
-			WHERE gs."gameScored" = true
-			AND gs.gameseason IS NOT NULL
-			AND (
-				-- Count of scored games in this week/season
-				SELECT COUNT(*) FROM pickem_api_gamesandscores gs2 
-				WHERE gs2."gameWeek" = gs."gameWeek" 
-				AND gs2.gameseason = gs.gameseason 
-				AND gs2."gameScored" = true
-				AND gs2.gameseason IS NOT NULL
-			) = (
-				-- Count of correct picks by user in this week/season
-				SELECT COUNT(*) FROM pickem_api_gamepicks gp 
-				WHERE gp."gameWeek" = gs."gameWeek" 
-				AND gp.gameseason = gs.gameseason 
-				AND gp."uid" = $1 
-				AND gp.pick_correct = true
-				AND gp.gameseason IS NOT NULL
-			)
-			AND (
-				-- Ensure user made picks for ALL scored games (no missed picks)
-				SELECT COUNT(*) FROM pickem_api_gamesandscores gs3
-				WHERE gs3."gameWeek" = gs."gameWeek" 
-				AND gs3.gameseason = gs.gameseason 
-				AND gs3."gameScored" = true
-				AND gs3.gameseason IS NOT NULL
-			) = (
-				-- Count of total picks by user in this week/season
-				SELECT COUNT(*) FROM pickem_api_gamepicks gp2 
-				WHERE gp2."gameWeek" = gs."gameWeek" 
-				AND gp2.gameseason = gs.gameseason 
-				AND gp2."uid" = $1
-				AND gp2.gameseason IS NOT NULL
-			)`
+		stats.WeeksWonTotal = dbUtil.IntPtr(weeksWonTotal)
+		stats.WeeksWonSeason = dbUtil.IntPtr(weeksWonSeason)
+		stats.SeasonsWon = dbUtil.IntPtr(seasonsWon)
 
-		err = db.QueryRow(perfectWeeksTotalQuery, uid).Scan(&perfectWeeksTotal)
-		if err != nil {
-			log.Printf("Error getting total perfect weeks for UID %s: %v", uid, err)
-			perfectWeeksTotal = 0
+		// Missed picks
+		var missedTotal, missedSeason int
+		for _, g := range scoredGames {
+			if pickedGames[uid][g.ID] {
+				continue
+			}
+			missedTotal++
+			if g.Season == season {
+				missedSeason++
+			}
 		}
-		stats.PerfectWeeksTotal = dbUtil.IntPtr(perfectWeeksTotal)
+		stats.MissedPicksTotal = dbUtil.IntPtr(missedTotal)
+		stats.MissedPicksSeason = dbUtil.IntPtr(missedSeason)
 
-		// Upsert the user stats
-		if err := dbUtil.UpsertUserStats(db, stats); err != nil {
-			log.Printf("Error upserting user stats for UID %s: %v", uid, err)
-		} else {
-			log.Printf("✓ UID: %s | Weeks Won: %d/%d | Seasons Won: %d | Missed Picks: %d/%d | Perfect Weeks: %d/%d", uid, weeksWonSeason, weeksWonTotal, seasonsWon, missedPicksSeason, missedPicksTotal, perfectWeeksSeason, perfectWeeksTotal)
+		// Perfect weeks
+		var perfectTotal, perfectSeason int
+		for key, scored := range scoredPerWeek {
+			if !picksPerWeek[uid][key].perfect(scored) {
+				continue
+			}
+			perfectTotal++
+			if key.season == season {
+				perfectSeason++
+			}
 		}
+		stats.PerfectWeeksTotal = dbUtil.IntPtr(perfectTotal)
+		stats.PerfectWeeksSeason = dbUtil.IntPtr(perfectSeason)
+
+		allStats = append(allStats, stats)
 	}
+	return allStats
 }
@@ -0,0 +1,160 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
+)
+
+// mustSeason parses a season code or fails the test
+func mustSeason(t *testing.T, code string) season.Season {
+	t.Helper()
+	s, err := season.Parse(code)
+	if err != nil {
+		t.Fatalf("season.Parse(%q): %v", code, err)
+	}
+	return s
+}
+
+// fields returns stats as a map of pickem_api_userstats column to value,
+// leaving out the generated ID
+func fields(stats *dbUtil.UserStats) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, f := range stats.StatFields() {
+		m[f.Name] = f.Value
+	}
+	return m
+}
+
+// byUser returns the fields of every record, keyed by user ID
+func byUser(batch []*dbUtil.UserStats) map[string]map[string]interface{} {
+	m := make(map[string]map[string]interface{}, len(batch))
+	for _, stats := range batch {
+		m[stats.UserID] = fields(stats)
+	}
+	return m
+}
+
+// legacyCorrectPicks is the per-user path CorrectPicksByUid replaced: the
+// distinct uids, then the email and four count(*) queries for each user,
+// applied to fixture rows instead of SQL
+func legacyCorrectPicks(picks []dbUtil.Pick, emails map[string]string, currentSeason string) []*dbUtil.UserStats {
+	// SELECT DISTINCT(uid) FROM pickem_api_gamepicks WHERE gameseason IS NOT NULL
+	var uids []string
+	seen := make(map[string]bool)
+	for _, p := range picks {
+		if p.Season != "" && !seen[p.UID] {
+			seen[p.UID] = true
+			uids = append(uids, p.UID)
+		}
+	}
+
+	count := func(uid string, correctOnly bool, season string) int {
+		n := 0
+		for _, p := range picks {
+			if p.UID != uid || p.Season == "" || (correctOnly && !p.Correct) || (season != "" && p.Season != season) {
+				continue
+			}
+			n++
+		}
+		return n
+	}
+	percent := func(correct, total int) int {
+		if total > 0 {
+			return int(float64(correct) / float64(total) * 100)
+		}
+		return 0
+	}
+
+	var allStats []*dbUtil.UserStats
+	for _, uid := range uids {
+		stats := dbUtil.NewUserStats(uid, dbUtil.EmailFor(emails, uid))
+		correctTotal, picksTotal := count(uid, true, ""), count(uid, false, "")
+		stats.CorrectPickTotalTotal = dbUtil.IntPtr(correctTotal)
+		stats.TotalPicksTotal = dbUtil.IntPtr(picksTotal)
+		stats.PickPercentTotal = dbUtil.IntPtr(percent(correctTotal, picksTotal))
+		correctSeason, picksSeason := count(uid, true, currentSeason), count(uid, false, currentSeason)
+		stats.CorrectPickTotalSeason = dbUtil.IntPtr(correctSeason)
+		stats.TotalPicksSeason = dbUtil.IntPtr(picksSeason)
+		stats.PickPercentSeason = dbUtil.IntPtr(percent(correctSeason, picksSeason))
+		allStats = append(allStats, stats)
+	}
+	return allStats
+}
+
+// TestCorrectPicksByUidMatchesLegacy checks the grouped count against the
+// per-user queries it replaced
+func TestCorrectPicksByUidMatchesLegacy(t *testing.T) {
+	tests := []struct {
+		name  string
+		picks []dbUtil.Pick
+	}{
+		{
+			name: "current and past seasons",
+			picks: []dbUtil.Pick{
+				{UID: "1", Season: "2425", Week: 1, GameID: "10", Correct: true, Graded: true},
+				{UID: "1", Season: "2425", Week: 1, GameID: "11", Graded: true},
+				{UID: "1", Season: "2324", Week: 1, GameID: "1", Correct: true, Graded: true},
+				{UID: "2", Season: "2425", Week: 1, GameID: "10", Graded: true},
+				{UID: "2", Season: "2425", Week: 1, GameID: "11", Correct: true, Graded: true},
+				{UID: "2", Season: "2425", Week: 2, GameID: "12", Correct: true, Graded: true},
+			},
+		},
+		{
+			name: "only past season picks",
+			picks: []dbUtil.Pick{
+				{UID: "3", Season: "2324", Week: 1, GameID: "1", Correct: true, Graded: true},
+				{UID: "3", Season: "2324", Week: 1, GameID: "2", Correct: true, Graded: true},
+			},
+		},
+		{
+			name: "ungraded picks and picks without a season",
+			picks: []dbUtil.Pick{
+				{UID: "4", Season: "2425", Week: 3, GameID: "20"},
+				{UID: "4", Season: "2425", Week: 3, GameID: "21"},
+				{UID: "4", Week: 3, GameID: "22", Correct: true, Graded: true},
+				{UID: "5", Week: 3, GameID: "22", Correct: true, Graded: true},
+			},
+		},
+		{
+			name: "percentages truncate",
+			picks: []dbUtil.Pick{
+				{UID: "6", Season: "2425", GameID: "1", Correct: true, Graded: true},
+				{UID: "6", Season: "2425", GameID: "2", Correct: true, Graded: true},
+				{UID: "6", Season: "2425", GameID: "3", Graded: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := dbUtil.NewMemoryStore()
+			store.PickRows = tt.picks
+			store.Emails["1"] = "one@example.com"
+
+			got, err := CorrectPicksByUid(context.Background(), store, mustSeason(t, "2425"))
+			if err != nil {
+				t.Fatalf("CorrectPicksByUid: %v", err)
+			}
+			want := legacyCorrectPicks(tt.picks, store.Emails, "2425")
+			if !reflect.DeepEqual(byUser(got), byUser(want)) {
+				t.Errorf("grouped totals differ from the per-user queries\n got: %v\nwant: %v", byUser(got), byUser(want))
+			}
+			if !reflect.DeepEqual(byUser(got), byUser(storedStats(store))) {
+				t.Errorf("stored records differ from the returned ones")
+			}
+		})
+	}
+}
+
+// storedStats returns the records upserted into store
+func storedStats(store *dbUtil.MemoryStore) []*dbUtil.UserStats {
+	var stats []*dbUtil.UserStats
+	for _, s := range store.Stats {
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,300 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ChampionCheck compares the computed and stored year_winner flag of one
+// user for one season. Stored is nil when the user has no
+// pickem_api_userseasonpoints row for the season.
+type ChampionCheck struct {
+	Season   string
+	UserID   string
+	Rank     int
+	Computed bool
+	Stored   *bool
+}
+
+// Agrees reports whether the stored flag matches the computed one. A
+// missing row agrees with a computed loss.
+func (c ChampionCheck) Agrees() bool {
+	if c.Stored == nil {
+		return !c.Computed
+	}
+	return *c.Stored == c.Computed
+}
+
+// ParseChampionRule splits a comma separated champion rule: the ranking
+// key followed by its tie-breakers
+func ParseChampionRule(rule string) ([]string, error) {
+	keys, err := ParseTieBreakers(rule)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no ranking keys given")
+	}
+	return keys, nil
+}
+
+// SeasonChampion represents the seasonChampion command
+var SeasonChampion = &cobra.Command{
+	Use:   "seasonChampion",
+	Short: "Decide season champions and check year_winner",
+	Long: `Season Champion Calculation
+			For every season whose final week has been played and whose games
+			are all scored, rank users by the champion.rank_by rule, counting
+			week wins computed from the picks, and report where year_winner
+			disagrees with the winner(s), or fix the flags with --apply`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rule, err := ParseChampionRule(viper.GetString("champion.rank_by"))
+		if err != nil {
+			return fmt.Errorf("champion.rank_by: %w", err)
+		}
+		tieBreak := viper.GetString("week_winners.tie_break")
+		if err := CheckTieBreak(tieBreak); err != nil {
+			return fmt.Errorf("week_winners.tie_break: %w", err)
+		}
+		seasonCode, _ := cmd.Flags().GetString("season")
+		if seasonCode != "" {
+			s, err := season.Parse(seasonCode)
+			if err != nil {
+				return fmt.Errorf("--season: %w", err)
+			}
+			seasonCode = s.String()
+		}
+		finalWeek, _ := cmd.Flags().GetInt("final-week")
+		if finalWeek < 1 || finalWeek > dbUtil.SeasonWeeks {
+			return fmt.Errorf("--final-week: must be between 1 and %d, got %d", dbUtil.SeasonWeeks, finalWeek)
+		}
+		apply, _ := cmd.Flags().GetBool("apply")
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		store := dbUtil.NewPostgresStore(database)
+		checks, err := CheckSeasonChampions(ctx, store, seasonCode, finalWeek, rule, tieBreak)
+		if err != nil {
+			return err
+		}
+
+		var disagreements []ChampionCheck
+		for _, c := range checks {
+			if !c.Agrees() {
+				disagreements = append(disagreements, c)
+			}
+		}
+		if err := output.Write(cmd.OutOrStdout(), output.Format(), championTable(disagreements)); err != nil {
+			return err
+		}
+		log.Printf("%d of %d year_winner flags disagree with the %s ranking", len(disagreements), len(checks), strings.Join(rule, ", "))
+
+		if !apply || len(disagreements) == 0 {
+			return nil
+		}
+		if viper.GetBool("dry_run") {
+			log.Printf("Dry run: not applying")
+			return nil
+		}
+		return ApplySeasonChampions(ctx, store, disagreements)
+	},
+}
+
+func init() {
+	SeasonChampion.Flags().String("season", "", "Only decide this season (YYZZ), which must be fully scored (default: every fully scored season)")
+	SeasonChampion.Flags().Int("final-week", dbUtil.SeasonWeeks, "Last week of the season, which must have games and all of them scored")
+	SeasonChampion.Flags().Bool("apply", false, "Write the computed year_winner flags where they disagree")
+	config.RegisterCheck("champion.rank_by", func(rule string) error {
+		_, err := ParseChampionRule(rule)
+		return err
+	})
+}
+
+// CheckSeasonChampions ranks every complete season (or just seasonCode,
+// which must be complete) by rule, using the leaderboard ranking, and
+// compares the rank 1 user(s) with the year_winner flags. A season is
+// complete when finalWeek has games and every loaded game is scored, so a
+// partially imported season is not decided early. Weeks won are the week
+// winners computed from the picks under tieBreak rather than the stored
+// week_N_winner flags, and any disagreement between the two is logged. It
+// returns a check for every ranked or flagged user, ordered by season and
+// rank.
+func CheckSeasonChampions(ctx context.Context, store dbUtil.Store, seasonCode string, finalWeek int, rule []string, tieBreak string) ([]ChampionCheck, error) {
+	if len(rule) == 0 {
+		return nil, fmt.Errorf("no ranking keys given")
+	}
+	if err := CheckRankKeys(rule...); err != nil {
+		return nil, err
+	}
+	if err := CheckTieBreak(tieBreak); err != nil {
+		return nil, err
+	}
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting games: %w", err)
+	}
+
+	type seasonGames struct{ total, scored, finalWeek int }
+	bySeason := make(map[string]*seasonGames)
+	for _, g := range games {
+		if bySeason[g.Season] == nil {
+			bySeason[g.Season] = &seasonGames{}
+		}
+		bySeason[g.Season].total++
+		if g.Scored {
+			bySeason[g.Season].scored++
+		}
+		if g.Week == finalWeek {
+			bySeason[g.Season].finalWeek++
+		}
+	}
+
+	var complete []string
+	for code, sg := range bySeason {
+		if sg.finalWeek > 0 && sg.scored == sg.total {
+			complete = append(complete, code)
+		}
+	}
+	sort.Slice(complete, func(i, j int) bool { return seasonLess(complete[i], complete[j]) })
+	if seasonCode != "" && len(complete) == 0 {
+		sg := bySeason[seasonCode]
+		switch {
+		case sg == nil:
+			return nil, fmt.Errorf("season %s has no games", seasonCode)
+		case sg.finalWeek == 0:
+			return nil, fmt.Errorf("season %s is not over: week %d has no games", seasonCode, finalWeek)
+		default:
+			return nil, fmt.Errorf("season %s is not over: %d of %d games scored", seasonCode, sg.scored, sg.total)
+		}
+	}
+
+	var checks []ChampionCheck
+	for _, code := range complete {
+		picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: code})
+		if err != nil {
+			return nil, fmt.Errorf("error getting picks: %w", err)
+		}
+		points, err := store.SeasonPoints(ctx, code)
+		if err != nil {
+			return nil, fmt.Errorf("error getting season points: %w", err)
+		}
+		var codeGames []dbUtil.Game
+		for _, g := range games {
+			if g.Season == code {
+				codeGames = append(codeGames, g)
+			}
+		}
+
+		weekChecks := weekWinnerChecks(picks, codeGames, points, tieBreak)
+		if n := len(weekWinnerDisagreements(weekChecks)); n > 0 {
+			log.Printf("Season %s: %d week_N_winner flag(s) disagree with the picks; ranking on the computed week winners (see `pickemcli weekWinners`)", code, n)
+		}
+		opts := LeaderboardOptions{Scope: code, RankBy: rule[0], TieBreakers: rule[1:]}
+		entries := rankStandings(standingsThrough(picks, codeGames, computedWeekWinners(weekChecks), latestScoredWeek(codeGames)), opts)
+
+		var champions []string
+		seen := make(map[string]bool)
+		for _, e := range entries {
+			if e.Rank == 1 {
+				champions = append(champions, e.UserID)
+			}
+			seen[e.UserID] = true
+			checks = append(checks, ChampionCheck{Season: code, UserID: e.UserID, Rank: e.Rank, Computed: e.Rank == 1})
+		}
+		log.Printf("Season %s champion(s): %s", code, strings.Join(champions, ", "))
+
+		stored := make(map[string]bool)
+		for _, sp := range points {
+			stored[sp.UserID] = sp.YearWinner
+			if sp.YearWinner && !seen[sp.UserID] {
+				// Flagged without a single pick
+				checks = append(checks, ChampionCheck{Season: code, UserID: sp.UserID})
+			}
+		}
+		for i := range checks {
+			if checks[i].Season != code {
+				continue
+			}
+			if won, ok := stored[checks[i].UserID]; ok {
+				checks[i].Stored = &won
+			}
+		}
+	}
+	return checks, nil
+}
+
+// computedWeekWinners turns the computed side of checks into season points
+// rows, so the leaderboard counts them in place of the stored flags
+func computedWeekWinners(checks []WeekWinnerCheck) []dbUtil.SeasonPoints {
+	byUser := make(map[string]*dbUtil.SeasonPoints)
+	var users []string
+	for _, c := range checks {
+		if !c.Computed {
+			continue
+		}
+		key := c.UserID + "/" + c.Season
+		if byUser[key] == nil {
+			byUser[key] = &dbUtil.SeasonPoints{UserID: c.UserID, Season: c.Season}
+			users = append(users, key)
+		}
+		byUser[key].WeekWinner[c.Week-1] = true
+	}
+	points := make([]dbUtil.SeasonPoints, len(users))
+	for i, key := range users {
+		points[i] = *byUser[key]
+	}
+	return points
+}
+
+// ApplySeasonChampions writes the computed flag of every check that
+// disagrees and has a season points row to write to
+func ApplySeasonChampions(ctx context.Context, store dbUtil.Store, checks []ChampionCheck) error {
+	var updates []dbUtil.YearWinnerUpdate
+	for _, c := range checks {
+		if c.Agrees() {
+			continue
+		}
+		if c.Stored == nil {
+			log.Printf("Not applying season %s winner for userID %s: no season points row", c.Season, c.UserID)
+			continue
+		}
+		updates = append(updates, dbUtil.YearWinnerUpdate{UserID: c.UserID, Season: c.Season, Winner: c.Computed})
+	}
+	return store.UpdateYearWinners(ctx, updates)
+}
+
+// championTable lays out checks for output.Write
+func championTable(checks []ChampionCheck) output.Table {
+	t := output.Table{Columns: []string{"season", "userID", "rank", "computed", "stored"}}
+	for _, c := range checks {
+		var rank, stored interface{}
+		if c.Rank > 0 {
+			rank = c.Rank
+		}
+		if c.Stored != nil {
+			stored = *c.Stored
+		}
+		t.Append(c.Season, c.UserID, rank, c.Computed, stored)
+	}
+	return t
+}
@@ -0,0 +1,87 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+func TestCheckSeasonChampionsFinalWeek(t *testing.T) {
+	rule := []string{"weeks_won", "correct_picks"}
+	store := fixtureStore()
+
+	// Neither fixture season has reached week 18
+	checks, err := CheckSeasonChampions(context.Background(), store, "", 18, rule, TieShare)
+	if err != nil {
+		t.Fatalf("CheckSeasonChampions: %v", err)
+	}
+	if len(checks) != 0 {
+		t.Errorf("decided %d users of a partially imported season", len(checks))
+	}
+	_, err = CheckSeasonChampions(context.Background(), store, "2324", 18, rule, TieShare)
+	if err == nil || !strings.Contains(err.Error(), "week 18 has no games") {
+		t.Errorf("CheckSeasonChampions(2324) error = %v, want week 18 has no games", err)
+	}
+
+	// With week 1 as the final week, 2324 is over; 2425 still has an
+	// unscored game
+	checks, err = CheckSeasonChampions(context.Background(), store, "", 1, rule, TieShare)
+	if err != nil {
+		t.Fatalf("CheckSeasonChampions: %v", err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("got %d checks, want 2: %+v", len(checks), checks)
+	}
+	for i, want := range []struct {
+		uid      string
+		rank     int
+		computed bool
+	}{{"1", 1, true}, {"4", 2, false}} {
+		c := checks[i]
+		if c.Season != "2324" || c.UserID != want.uid || c.Rank != want.rank || c.Computed != want.computed || !c.Agrees() {
+			t.Errorf("checks[%d] = %+v, want userID %s rank %d computed %v agreeing", i, c, want.uid, want.rank, want.computed)
+		}
+	}
+	_, err = CheckSeasonChampions(context.Background(), store, "2425", 1, rule, TieShare)
+	if err == nil || !strings.Contains(err.Error(), "3 of 4 games scored") {
+		t.Errorf("CheckSeasonChampions(2425) error = %v, want 3 of 4 games scored", err)
+	}
+}
+
+func TestCheckSeasonChampionsComputesWeekWinners(t *testing.T) {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2324", Week: 1, Scored: true},
+		{ID: "2", Season: "2324", Week: 1, Scored: true},
+		{ID: "3", Season: "2324", Week: 2, Scored: true},
+	}
+	// 1 wins both weeks on the picks
+	store.PickRows = []dbUtil.Pick{
+		{UID: "1", Season: "2324", Week: 1, GameID: "1", Correct: true, Graded: true},
+		{UID: "1", Season: "2324", Week: 1, GameID: "2", Correct: true, Graded: true},
+		{UID: "1", Season: "2324", Week: 2, GameID: "3", Correct: true, Graded: true},
+		{UID: "2", Season: "2324", Week: 1, GameID: "1", Correct: true, Graded: true},
+		{UID: "2", Season: "2324", Week: 1, GameID: "2", Graded: true},
+		{UID: "2", Season: "2324", Week: 2, GameID: "3", Graded: true},
+	}
+	// but the stored flags give both weeks, and the season, to 2
+	store.SeasonPointsRows = []dbUtil.SeasonPoints{
+		{UserID: "1", Season: "2324"},
+		{UserID: "2", Season: "2324", WeekWinner: weeksWon(1, 2), YearWinner: true},
+	}
+
+	got, err := CheckSeasonChampions(context.Background(), store, "2324", 2, []string{RankWeeksWon}, TieShare)
+	if err != nil {
+		t.Fatalf("CheckSeasonChampions: %v", err)
+	}
+	want := []ChampionCheck{
+		{Season: "2324", UserID: "1", Rank: 1, Computed: true, Stored: flag(false)},
+		{Season: "2324", UserID: "2", Rank: 2, Stored: flag(true)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckSeasonChampions\n got: %+v\nwant: %+v", got, want)
+	}
+}
@@ -0,0 +1,307 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// pairCount is how two users' picks line up on the games both picked
+type pairCount struct {
+	shared, same int
+}
+
+// Similarity is the user × user agreement matrix: for every pair of users,
+// the games both picked and how many of them they picked the same side of
+type Similarity struct {
+	// Users lists every user with a pick, ordered by user ID
+	Users []string
+	pairs map[[2]string]pairCount
+}
+
+// Pair returns the shared and same-side game counts of users a and b
+func (s *Similarity) Pair(a, b string) (shared, same int) {
+	if b < a {
+		a, b = b, a
+	}
+	c := s.pairs[[2]string{a, b}]
+	return c.shared, c.same
+}
+
+// Agreement returns the whole percentage of the games a and b both
+// picked that they picked the same side of, and false when they share
+// fewer than minShared games
+func (s *Similarity) Agreement(a, b string, minShared int) (int, bool) {
+	shared, same := s.Pair(a, b)
+	if shared == 0 || shared < minShared {
+		return 0, false
+	}
+	return pickPercent(same, shared), true
+}
+
+// PickTwin names a user's closest and most opposite users among those
+// sharing at least the minimum number of games with them. Ties are joined
+// with ", ", and the fields are empty when nobody qualifies.
+type PickTwin struct {
+	UserID            string
+	Twin              string
+	TwinAgreement     int
+	Opposite          string
+	OppositeAgreement int
+}
+
+// CheckClusterThreshold returns an error unless threshold is a percentage
+// between 1 and 100
+func CheckClusterThreshold(threshold int) error {
+	if threshold < 1 || threshold > 100 {
+		return fmt.Errorf("must be between 1 and 100, got %d", threshold)
+	}
+	return nil
+}
+
+// SimilarityCmd represents the similarity command
+var SimilarityCmd = &cobra.Command{
+	Use:   "similarity",
+	Short: "Find which users pick alike",
+	Long: `Pick Similarity
+			Build a user × user agreement matrix from pickem_api_gamepicks, the share
+			of shared games where both users picked the same side, and list each
+			user's pick twin and most opposite user, or the clusters of users who
+			pick alike`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		seasonCode, _ := cmd.Flags().GetString("season")
+		if seasonCode != "" {
+			s, err := season.Parse(seasonCode)
+			if err != nil {
+				return fmt.Errorf("--season: %w", err)
+			}
+			seasonCode = s.String()
+		}
+		minShared := viper.GetInt("similarity.min_shared")
+		if minShared < 1 {
+			return fmt.Errorf("similarity.min_shared: must be at least 1, got %d", minShared)
+		}
+		threshold := viper.GetInt("similarity.cluster_threshold")
+		if err := CheckClusterThreshold(threshold); err != nil {
+			return fmt.Errorf("similarity.cluster_threshold: %w", err)
+		}
+		matrix, _ := cmd.Flags().GetBool("matrix")
+		clusters, _ := cmd.Flags().GetBool("clusters")
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		sim, err := PickSimilarity(ctx, dbUtil.NewPostgresStore(database), seasonCode)
+		if err != nil {
+			return err
+		}
+		switch {
+		case matrix:
+			return output.Write(cmd.OutOrStdout(), output.Format(), similarityTable(sim, minShared))
+		case clusters:
+			return output.Write(cmd.OutOrStdout(), output.Format(), clusterTable(PickClusters(sim, minShared, threshold)))
+		}
+		return output.Write(cmd.OutOrStdout(), output.Format(), pickTwinTable(PickTwins(sim, minShared)))
+	},
+}
+
+func init() {
+	SimilarityCmd.Flags().String("season", "", "Only compare picks from this season (YYZZ) (default: all time)")
+	SimilarityCmd.Flags().Int("min-shared", 0, "Games two users must both have picked to be compared (default: similarity.min_shared)")
+	SimilarityCmd.Flags().Bool("matrix", false, "Print the full agreement matrix instead of each user's twin and opposite")
+	SimilarityCmd.Flags().Bool("clusters", false, "Print the clusters of users who pick alike instead of each user's twin and opposite")
+	SimilarityCmd.Flags().Int("cluster-threshold", 0, "Agreement, in percent, that links two users into a cluster (default: similarity.cluster_threshold)")
+	SimilarityCmd.MarkFlagsMutuallyExclusive("matrix", "clusters")
+	if err := viper.BindPFlag("similarity.min_shared", SimilarityCmd.Flags().Lookup("min-shared")); err != nil {
+		panic(err.Error())
+	}
+	if err := viper.BindPFlag("similarity.cluster_threshold", SimilarityCmd.Flags().Lookup("cluster-threshold")); err != nil {
+		panic(err.Error())
+	}
+	config.RegisterIntCheck("similarity.min_shared", config.AtLeast(1))
+	config.RegisterIntCheck("similarity.cluster_threshold", CheckClusterThreshold)
+}
+
+// PickSimilarity counts, for every pair of users, the games in seasonCode
+// (or all time when it is empty) both picked and how many of them they
+// picked the same side of
+func PickSimilarity(ctx context.Context, store dbUtil.Store, seasonCode string) (*Similarity, error) {
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+
+	byGame := make(map[string]map[string]string)
+	users := make(map[string]bool)
+	for _, p := range picks {
+		key := dbUtil.TeamKey(p.Team)
+		if p.Season == "" || key == "" {
+			continue
+		}
+		if byGame[p.GameID] == nil {
+			byGame[p.GameID] = make(map[string]string)
+		}
+		byGame[p.GameID][p.UID] = key
+		users[p.UID] = true
+	}
+
+	sim := &Similarity{Users: sortedKeys(users), pairs: make(map[[2]string]pairCount)}
+	for _, teams := range byGame {
+		uids := sortedKeys(teams)
+		for i, a := range uids {
+			for _, b := range uids[i+1:] {
+				c := sim.pairs[[2]string{a, b}]
+				c.shared++
+				if teams[a] == teams[b] {
+					c.same++
+				}
+				sim.pairs[[2]string{a, b}] = c
+			}
+		}
+	}
+	return sim, nil
+}
+
+// PickTwins finds every user's pick twin, the user they agree with most,
+// and most opposite user, the one they agree with least, among users
+// sharing at least minShared games with them. Agreement is compared
+// exactly rather than on the rounded percentage.
+func PickTwins(sim *Similarity, minShared int) []PickTwin {
+	// compare orders pair counts by agreement without rounding
+	compare := func(x, y pairCount) int {
+		return x.same*y.shared - y.same*x.shared
+	}
+
+	twins := make([]PickTwin, len(sim.Users))
+	for i, a := range sim.Users {
+		twins[i].UserID = a
+
+		var closest, opposite []string
+		var closestCount, oppositeCount pairCount
+		for _, b := range sim.Users {
+			if a == b {
+				continue
+			}
+			shared, same := sim.Pair(a, b)
+			if shared == 0 || shared < minShared {
+				continue
+			}
+			c := pairCount{shared, same}
+			switch {
+			case closest == nil || compare(c, closestCount) > 0:
+				closest, closestCount = []string{b}, c
+			case compare(c, closestCount) == 0:
+				closest = append(closest, b)
+			}
+			switch {
+			case opposite == nil || compare(c, oppositeCount) < 0:
+				opposite, oppositeCount = []string{b}, c
+			case compare(c, oppositeCount) == 0:
+				opposite = append(opposite, b)
+			}
+		}
+		if closest != nil {
+			twins[i].Twin = strings.Join(closest, ", ")
+			twins[i].TwinAgreement = pickPercent(closestCount.same, closestCount.shared)
+			twins[i].Opposite = strings.Join(opposite, ", ")
+			twins[i].OppositeAgreement = pickPercent(oppositeCount.same, oppositeCount.shared)
+		}
+	}
+	return twins
+}
+
+// PickClusters groups users who pick alike: two users are linked when
+// they share at least minShared games and agree on at least threshold
+// percent of them, and a cluster is every user reachable through such
+// links (a connected component). Clusters of a single user are left out.
+// Members are ordered by user ID and clusters by their first member.
+func PickClusters(sim *Similarity, minShared, threshold int) [][]string {
+	linked := func(a, b string) bool {
+		shared, same := sim.Pair(a, b)
+		return shared > 0 && shared >= minShared && same*100 >= threshold*shared
+	}
+
+	var clusters [][]string
+	seen := make(map[string]bool)
+	for _, start := range sim.Users {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		cluster := []string{start}
+		for i := 0; i < len(cluster); i++ {
+			for _, b := range sim.Users {
+				if !seen[b] && linked(cluster[i], b) {
+					seen[b] = true
+					cluster = append(cluster, b)
+				}
+			}
+		}
+		if len(cluster) > 1 {
+			sort.Strings(cluster)
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// similarityTable lays out the agreement matrix for output.Write, one row
+// and one column per user. Pairs sharing fewer than minShared games, and
+// each user with themselves, are left empty.
+func similarityTable(sim *Similarity, minShared int) output.Table {
+	t := output.Table{Columns: append([]string{"userID"}, sim.Users...)}
+	for _, a := range sim.Users {
+		row := []interface{}{a}
+		for _, b := range sim.Users {
+			var cell interface{}
+			if agreement, ok := sim.Agreement(a, b, minShared); ok && a != b {
+				cell = agreement
+			}
+			row = append(row, cell)
+		}
+		t.Append(row...)
+	}
+	return t
+}
+
+// pickTwinTable lays out twins for output.Write. Users without anyone to
+// compare with get empty cells.
+func pickTwinTable(twins []PickTwin) output.Table {
+	t := output.Table{Columns: []string{"userID", "twin", "twinAgreement", "opposite", "oppositeAgreement"}}
+	for _, tw := range twins {
+		var twin, twinAgreement, opposite, oppositeAgreement interface{}
+		if tw.Twin != "" {
+			twin, twinAgreement = tw.Twin, tw.TwinAgreement
+			opposite, oppositeAgreement = tw.Opposite, tw.OppositeAgreement
+		}
+		t.Append(tw.UserID, twin, twinAgreement, opposite, oppositeAgreement)
+	}
+	return t
+}
+
+// clusterTable lays out clusters for output.Write, one row per cluster
+// numbered from 1
+func clusterTable(clusters [][]string) output.Table {
+	t := output.Table{Columns: []string{"cluster", "size", "users"}}
+	for i, users := range clusters {
+		t.Append(i+1, len(users), strings.Join(users, ", "))
+	}
+	return t
+}
@@ -0,0 +1,103 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// similarityStore returns a MemoryStore where a and b (with differently
+// written team names) pick alike, c differs from them on one game of four,
+// d picks against everyone and e picked a single game
+func similarityStore() *dbUtil.MemoryStore {
+	store := dbUtil.NewMemoryStore()
+	picks := map[string][]string{
+		"a": {"Jets", "Jets", "Jets", "Jets"},
+		"b": {"jets", " JETS", "Jets ", "Jets"},
+		"c": {"Jets", "Jets", "Jets", "Bills"},
+		"d": {"Bills", "Bills", "Bills", "Jets"},
+		"e": {"Jets"},
+	}
+	games := []string{"1", "2", "3", "4"}
+	for uid, teams := range picks {
+		for i, team := range teams {
+			store.PickRows = append(store.PickRows, dbUtil.Pick{UID: uid, Season: "2425", Week: i + 1, GameID: games[i], Team: team})
+		}
+	}
+	return store
+}
+
+func TestPickClusters(t *testing.T) {
+	sim, err := PickSimilarity(context.Background(), similarityStore(), "2425")
+	if err != nil {
+		t.Fatalf("PickSimilarity: %v", err)
+	}
+	if shared, same := sim.Pair("a", "b"); shared != 4 || same != 4 {
+		t.Errorf("a and b agree on %d of %d games, want 4 of 4", same, shared)
+	}
+
+	tests := []struct {
+		threshold int
+		want      [][]string
+	}{
+		{threshold: 100, want: [][]string{{"a", "b"}}},
+		{threshold: 75, want: [][]string{{"a", "b", "c"}}},
+		{threshold: 25, want: [][]string{{"a", "b", "c", "d"}}},
+	}
+	for _, tt := range tests {
+		if got := PickClusters(sim, 2, tt.threshold); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PickClusters(threshold %d) = %v, want %v", tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestPickTwins(t *testing.T) {
+	sim, err := PickSimilarity(context.Background(), similarityStore(), "2425")
+	if err != nil {
+		t.Fatalf("PickSimilarity: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		minShared int
+		want      []PickTwin
+	}{
+		{
+			name:      "e shares too few games",
+			minShared: 2,
+			want: []PickTwin{
+				{UserID: "a", Twin: "b", TwinAgreement: 100, Opposite: "d", OppositeAgreement: 25},
+				{UserID: "b", Twin: "a", TwinAgreement: 100, Opposite: "d", OppositeAgreement: 25},
+				{UserID: "c", Twin: "a, b", TwinAgreement: 75, Opposite: "d", OppositeAgreement: 0},
+				{UserID: "d", Twin: "a, b", TwinAgreement: 25, Opposite: "c", OppositeAgreement: 0},
+				{UserID: "e"},
+			},
+		},
+		{
+			name:      "every pair qualifies",
+			minShared: 1,
+			want: []PickTwin{
+				{UserID: "a", Twin: "b, e", TwinAgreement: 100, Opposite: "d", OppositeAgreement: 25},
+				{UserID: "b", Twin: "a, e", TwinAgreement: 100, Opposite: "d", OppositeAgreement: 25},
+				{UserID: "c", Twin: "e", TwinAgreement: 100, Opposite: "d", OppositeAgreement: 0},
+				{UserID: "d", Twin: "a, b", TwinAgreement: 25, Opposite: "c, e", OppositeAgreement: 0},
+				{UserID: "e", Twin: "a, b, c", TwinAgreement: 100, Opposite: "d", OppositeAgreement: 0},
+			},
+		},
+		{
+			name:      "nobody qualifies",
+			minShared: 5,
+			want:      []PickTwin{{UserID: "a"}, {UserID: "b"}, {UserID: "c"}, {UserID: "d"}, {UserID: "e"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PickTwins(sim, tt.minShared); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PickTwins(min shared %d)\n got: %+v\nwant: %+v", tt.minShared, got, tt.want)
+			}
+		})
+	}
+}
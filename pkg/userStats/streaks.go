@@ -0,0 +1,218 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+)
+
+// StreaksCmd represents the streaks command
+var StreaksCmd = &cobra.Command{
+	Use:   "streaks",
+	Short: "Compute pick and week streaks for a season or all time",
+	Long: `Streak Analytics
+			Walk every user's picks in game order and compute the longest and
+			current runs of correct picks, the longest run of wrong picks, the
+			longest and current runs of weeks won, and the longest run of weeks
+			without a missed pick, and store them in pickemcli_streaks`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope, _ := cmd.Flags().GetString("season")
+		scope, err := parseScope(scope, dbUtil.StreaksAllTime)
+		if err != nil {
+			return err
+		}
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		store, done, err := openStore(ctx, database)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		if scope, err = resolveScope(ctx, store, scope); err != nil {
+			return err
+		}
+
+		records, err := Streaks(ctx, store, scope)
+		if err != nil {
+			return err
+		}
+		if err := store.ReplaceStreaks(ctx, scope, records); err != nil {
+			return err
+		}
+		return output.Write(cmd.OutOrStdout(), output.Format(), streaksTable(records))
+	},
+}
+
+func init() {
+	StreaksCmd.Flags().String("season", "", `Season to compute (YYZZ), or "all" for all time (default: the current season)`)
+}
+
+// UpdateStreaks stores the current season's and the all-time streaks
+func UpdateStreaks(ctx context.Context, store dbUtil.Store, current season.Season) error {
+	for _, scope := range []string{current.String(), dbUtil.StreaksAllTime} {
+		records, err := Streaks(ctx, store, scope)
+		if err != nil {
+			return err
+		}
+		if err := store.ReplaceStreaks(ctx, scope, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Streaks computes the streaks of every user with a pick in scope, a
+// season or dbUtil.StreaksAllTime. Picks and weeks are walked in game
+// order: season, week, then game ID. Ungraded picks and picks on games
+// not yet scored are skipped rather than breaking a run.
+func Streaks(ctx context.Context, store dbUtil.Store, scope string) ([]dbUtil.StreakRecord, error) {
+	seasonFilter := scope
+	if scope == dbUtil.StreaksAllTime {
+		seasonFilter = ""
+	}
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonFilter})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonFilter, ScoredOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error getting scored games: %w", err)
+	}
+	points, err := store.SeasonPoints(ctx, seasonFilter)
+	if err != nil {
+		return nil, fmt.Errorf("error getting season points: %w", err)
+	}
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
+	}
+
+	sort.Slice(games, func(i, j int) bool { return gameLess(games[i], games[j]) })
+	order := make(map[string]int, len(games))
+	var weeks []seasonWeek
+	scoredPerWeek := make(map[seasonWeek]int)
+	for i, g := range games {
+		order[g.ID] = i
+		key := seasonWeek{g.Season, g.Week}
+		if scoredPerWeek[key] == 0 {
+			weeks = append(weeks, key)
+		}
+		scoredPerWeek[key]++
+	}
+
+	// Each user's graded picks on scored games, in game order
+	byUID := make(map[string][]dbUtil.Pick)
+	for _, p := range picks {
+		if p.Season == "" {
+			continue
+		}
+		if _, ok := byUID[p.UID]; !ok {
+			byUID[p.UID] = nil
+		}
+		if _, scored := order[p.GameID]; scored && p.Graded {
+			byUID[p.UID] = append(byUID[p.UID], p)
+		}
+	}
+
+	won := make(map[string]map[seasonWeek]bool)
+	for _, sp := range points {
+		for i, w := range sp.WeekWinner {
+			if !w {
+				continue
+			}
+			if won[sp.UserID] == nil {
+				won[sp.UserID] = make(map[seasonWeek]bool)
+			}
+			won[sp.UserID][seasonWeek{sp.Season, i + 1}] = true
+		}
+	}
+
+	// Scored games picked per user and week, for the missed pick runs
+	picked := make(map[string]map[seasonWeek]map[string]bool)
+	for _, p := range picks {
+		if _, scored := order[p.GameID]; !scored {
+			continue
+		}
+		key := seasonWeek{p.Season, p.Week}
+		if picked[p.UID] == nil {
+			picked[p.UID] = make(map[seasonWeek]map[string]bool)
+		}
+		if picked[p.UID][key] == nil {
+			picked[p.UID][key] = make(map[string]bool)
+		}
+		picked[p.UID][key][p.GameID] = true
+	}
+
+	records := make([]dbUtil.StreakRecord, 0, len(byUID))
+	for _, uid := range sortedKeys(byUID) {
+		r := dbUtil.StreakRecord{Scope: scope, UserID: uid, UserEmail: dbUtil.EmailFor(emails, uid)}
+
+		userPicks := byUID[uid]
+		sort.SliceStable(userPicks, func(i, j int) bool { return order[userPicks[i].GameID] < order[userPicks[j].GameID] })
+		var correctRun, wrongRun streak
+		for _, p := range userPicks {
+			correctRun.next(p.Correct)
+			wrongRun.next(!p.Correct)
+		}
+		r.LongestCorrect, r.CurrentCorrect = correctRun.longest, correctRun.current
+		r.LongestWrong = wrongRun.longest
+
+		var wonRun, noMissedRun streak
+		for _, week := range weeks {
+			wonRun.next(won[uid][week])
+			noMissedRun.next(len(picked[uid][week]) == scoredPerWeek[week])
+		}
+		r.LongestWeeksWon, r.CurrentWeeksWon = wonRun.longest, wonRun.current
+		r.LongestNoMissed = noMissedRun.longest
+
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// streak tracks a run of consecutive hits
+type streak struct {
+	current, longest int
+}
+
+// next extends the run on a hit and ends it otherwise
+func (s *streak) next(hit bool) {
+	if !hit {
+		s.current = 0
+		return
+	}
+	s.current++
+	if s.current > s.longest {
+		s.longest = s.current
+	}
+}
+
+// streaksTable lays out records for output.Write
+func streaksTable(records []dbUtil.StreakRecord) output.Table {
+	t := output.Table{Columns: []string{
+		"userID", "userEmail", "longestCorrect", "currentCorrect", "longestWrong",
+		"longestWeeksWon", "currentWeeksWon", "longestNoMissed",
+	}}
+	for _, r := range records {
+		t.Append(r.UserID, r.UserEmail, r.LongestCorrect, r.CurrentCorrect, r.LongestWrong,
+			r.LongestWeeksWon, r.CurrentWeeksWon, r.LongestNoMissed)
+	}
+	return t
+}
@@ -0,0 +1,115 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// streakStore returns a MemoryStore with two scored weeks in 2324 and
+// three in 2425. Game 13 of 2425 week 2 is not scored yet.
+func streakStore() *dbUtil.MemoryStore {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2324", Week: 1, Scored: true},
+		{ID: "2", Season: "2324", Week: 1, Scored: true},
+		{ID: "3", Season: "2324", Week: 2, Scored: true},
+		{ID: "10", Season: "2425", Week: 1, Scored: true},
+		{ID: "11", Season: "2425", Week: 1, Scored: true},
+		{ID: "12", Season: "2425", Week: 2, Scored: true},
+		{ID: "13", Season: "2425", Week: 2},
+		{ID: "14", Season: "2425", Week: 3, Scored: true},
+	}
+	pick := func(uid, season, gameID string, week int, correct bool) dbUtil.Pick {
+		return dbUtil.Pick{UID: uid, Season: season, Week: week, GameID: gameID, Correct: correct, Graded: true}
+	}
+	store.PickRows = []dbUtil.Pick{
+		// 1: four correct across the season boundary, then a miss, then an
+		// ungraded pick on an unscored game between two correct ones
+		pick("1", "2324", "1", 1, true), pick("1", "2324", "2", 1, true), pick("1", "2324", "3", 2, true),
+		pick("1", "2425", "10", 1, true), pick("1", "2425", "11", 1, false), pick("1", "2425", "12", 2, true),
+		{UID: "1", Season: "2425", Week: 2, GameID: "13", Team: "A"},
+		pick("1", "2425", "14", 3, true),
+		// 2: three wrong across the season boundary, skipping game 3, and
+		// an ungraded pick on a scored game between two correct ones
+		pick("2", "2324", "1", 1, false), pick("2", "2324", "2", 1, false),
+		pick("2", "2425", "10", 1, false), pick("2", "2425", "11", 1, true),
+		{UID: "2", Season: "2425", Week: 2, GameID: "12", Team: "A"},
+		pick("2", "2425", "14", 3, true),
+	}
+	store.SeasonPointsRows = []dbUtil.SeasonPoints{
+		{UserID: "1", Season: "2324", WeekWinner: weeksWon(2)},
+		{UserID: "1", Season: "2425", WeekWinner: weeksWon(1, 2, 3)},
+		{UserID: "2", Season: "2324", WeekWinner: weeksWon(1)},
+		{UserID: "2", Season: "2425"},
+	}
+	store.Emails["1"] = "one@example.com"
+	store.Emails["2"] = "two@example.com"
+	return store
+}
+
+func TestStreaks(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  []dbUtil.StreakRecord
+	}{
+		{
+			scope: dbUtil.StreaksAllTime,
+			want: []dbUtil.StreakRecord{
+				{
+					Scope: dbUtil.StreaksAllTime, UserID: "1", UserEmail: "one@example.com",
+					LongestCorrect: 4, CurrentCorrect: 2, LongestWrong: 1,
+					LongestWeeksWon: 4, CurrentWeeksWon: 4, LongestNoMissed: 5,
+				},
+				{
+					Scope: dbUtil.StreaksAllTime, UserID: "2", UserEmail: "two@example.com",
+					LongestCorrect: 2, CurrentCorrect: 2, LongestWrong: 3,
+					LongestWeeksWon: 1, CurrentWeeksWon: 0, LongestNoMissed: 3,
+				},
+			},
+		},
+		{
+			scope: "2425",
+			want: []dbUtil.StreakRecord{
+				{
+					Scope: "2425", UserID: "1", UserEmail: "one@example.com",
+					LongestCorrect: 2, CurrentCorrect: 2, LongestWrong: 1,
+					LongestWeeksWon: 3, CurrentWeeksWon: 3, LongestNoMissed: 3,
+				},
+				{
+					Scope: "2425", UserID: "2", UserEmail: "two@example.com",
+					LongestCorrect: 2, CurrentCorrect: 2, LongestWrong: 1,
+					LongestWeeksWon: 0, CurrentWeeksWon: 0, LongestNoMissed: 3,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			got, err := Streaks(context.Background(), streakStore(), tt.scope)
+			if err != nil {
+				t.Fatalf("Streaks: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Streaks\n got: %+v\nwant: %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreaksCurrentWeeksWonEnds(t *testing.T) {
+	store := streakStore()
+	// 1 loses the latest week: the current run ends, the longest stays
+	store.SeasonPointsRows[1].WeekWinner = weeksWon(1, 2)
+
+	got, err := Streaks(context.Background(), store, dbUtil.StreaksAllTime)
+	if err != nil {
+		t.Fatalf("Streaks: %v", err)
+	}
+	if r := got[0]; r.LongestWeeksWon != 3 || r.CurrentWeeksWon != 0 {
+		t.Errorf("userID 1 weeks won: longest %d, current %d; want 3 and 0", r.LongestWeeksWon, r.CurrentWeeksWon)
+	}
+}
@@ -0,0 +1,275 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// TeamSummary names a user's best and worst picked teams. Teams picked
+// fewer than the minimum number of times are not considered; the fields
+// are empty when no team qualifies. Ties are joined with ", ".
+type TeamSummary struct {
+	UserID        string
+	UserEmail     string
+	BestTeam      string
+	BestAccuracy  int
+	WorstTeam     string
+	WorstAccuracy int
+}
+
+// TeamAccuracyCmd represents the teamAccuracy command
+var TeamAccuracyCmd = &cobra.Command{
+	Use:   "teamAccuracy",
+	Short: "Compute each user's pick accuracy per team",
+	Long: `Team Pick Accuracy
+			For each user and team, count the graded picks, the correct picks and
+			the accuracy, store the matrix in pickemcli_team_accuracy and name each
+			user's best and worst team`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope, _ := cmd.Flags().GetString("season")
+		scope, err := parseScope(scope, dbUtil.TeamAccuracyAllTime)
+		if err != nil {
+			return err
+		}
+		minPicks := viper.GetInt("team_accuracy.min_picks")
+		if minPicks < 1 {
+			return fmt.Errorf("team_accuracy.min_picks: must be at least 1, got %d", minPicks)
+		}
+		matrix, _ := cmd.Flags().GetBool("matrix")
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		store, done, err := openStore(ctx, database)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		if scope, err = resolveScope(ctx, store, scope); err != nil {
+			return err
+		}
+
+		rows, err := TeamAccuracy(ctx, store, scope)
+		if err != nil {
+			return err
+		}
+		if err := store.ReplaceTeamAccuracy(ctx, scope, rows); err != nil {
+			return err
+		}
+		if matrix {
+			return output.Write(cmd.OutOrStdout(), output.Format(), teamAccuracyTable(rows, minPicks))
+		}
+
+		emails, err := store.UserEmails(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting user emails: %w", err)
+		}
+		summaries := TeamSummaries(rows, minPicks)
+		for i := range summaries {
+			summaries[i].UserEmail = dbUtil.EmailFor(emails, summaries[i].UserID)
+		}
+		return output.Write(cmd.OutOrStdout(), output.Format(), teamSummaryTable(summaries))
+	},
+}
+
+func init() {
+	TeamAccuracyCmd.Flags().String("season", "", `Season to compute (YYZZ), or "all" for all time (default: the current season)`)
+	TeamAccuracyCmd.Flags().Int("min-picks", 0, "Graded picks of a team needed to be a best or worst team (default: team_accuracy.min_picks)")
+	TeamAccuracyCmd.Flags().Bool("matrix", false, "Print every user and team instead of each user's best and worst team")
+	if err := viper.BindPFlag("team_accuracy.min_picks", TeamAccuracyCmd.Flags().Lookup("min-picks")); err != nil {
+		panic(err.Error())
+	}
+	config.RegisterIntCheck("team_accuracy.min_picks", config.AtLeast(1))
+}
+
+// UpdateTeamAccuracy stores the current season's and the all-time team
+// accuracy matrices
+func UpdateTeamAccuracy(ctx context.Context, store dbUtil.Store, current season.Season) error {
+	for _, scope := range []string{current.String(), dbUtil.TeamAccuracyAllTime} {
+		rows, err := TeamAccuracy(ctx, store, scope)
+		if err != nil {
+			return err
+		}
+		if err := store.ReplaceTeamAccuracy(ctx, scope, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TeamAccuracy counts every user's graded picks and correct picks of each
+// team in scope, a season or dbUtil.TeamAccuracyAllTime. Picks are grouped
+// by dbUtil.TeamKey and named as the games name the team. Rows are ordered
+// by user ID and team.
+func TeamAccuracy(ctx context.Context, store dbUtil.Store, scope string) ([]dbUtil.TeamAccuracy, error) {
+	seasonFilter := scope
+	if scope == dbUtil.TeamAccuracyAllTime {
+		seasonFilter = ""
+	}
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonFilter})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonFilter})
+	if err != nil {
+		return nil, fmt.Errorf("error getting games: %w", err)
+	}
+	names := teamNames(games)
+
+	byUID := make(map[string]map[string]*dbUtil.TeamAccuracy)
+	for _, p := range picks {
+		key := dbUtil.TeamKey(p.Team)
+		if p.Season == "" || key == "" || !p.Graded {
+			continue
+		}
+		if byUID[p.UID] == nil {
+			byUID[p.UID] = make(map[string]*dbUtil.TeamAccuracy)
+		}
+		a, ok := byUID[p.UID][key]
+		if !ok {
+			a = &dbUtil.TeamAccuracy{Scope: scope, UserID: p.UID, Team: teamName(names, p.Team)}
+			byUID[p.UID][key] = a
+		}
+		a.Picks++
+		if p.Correct {
+			a.Correct++
+		}
+	}
+
+	var rows []dbUtil.TeamAccuracy
+	for _, uid := range sortedKeys(byUID) {
+		for _, team := range sortedKeys(byUID[uid]) {
+			a := byUID[uid][team]
+			a.Accuracy = pickPercent(a.Correct, a.Picks)
+			rows = append(rows, *a)
+		}
+	}
+	return rows, nil
+}
+
+// TeamSummaries picks each user's best and worst team from rows, ordered
+// by user ID and team as TeamAccuracy returns them, ignoring teams with
+// fewer than minPicks picks. Teams are compared on their exact accuracy,
+// not the rounded percentage.
+func TeamSummaries(rows []dbUtil.TeamAccuracy, minPicks int) []TeamSummary {
+	// compare orders a and b by accuracy without rounding
+	compare := func(a, b dbUtil.TeamAccuracy) int {
+		return a.Correct*b.Picks - b.Correct*a.Picks
+	}
+
+	var summaries []TeamSummary
+	var best, worst []dbUtil.TeamAccuracy
+	flush := func() {
+		s := &summaries[len(summaries)-1]
+		if len(best) > 0 {
+			s.BestTeam, s.BestAccuracy = joinTeams(best), best[0].Accuracy
+			s.WorstTeam, s.WorstAccuracy = joinTeams(worst), worst[0].Accuracy
+		}
+		best, worst = nil, nil
+	}
+	for _, row := range rows {
+		if len(summaries) == 0 || summaries[len(summaries)-1].UserID != row.UserID {
+			if len(summaries) > 0 {
+				flush()
+			}
+			summaries = append(summaries, TeamSummary{UserID: row.UserID})
+		}
+		if row.Picks < minPicks {
+			continue
+		}
+		switch {
+		case len(best) == 0 || compare(row, best[0]) > 0:
+			best = []dbUtil.TeamAccuracy{row}
+		case compare(row, best[0]) == 0:
+			best = append(best, row)
+		}
+		switch {
+		case len(worst) == 0 || compare(row, worst[0]) < 0:
+			worst = []dbUtil.TeamAccuracy{row}
+		case compare(row, worst[0]) == 0:
+			worst = append(worst, row)
+		}
+	}
+	if len(summaries) > 0 {
+		flush()
+	}
+	return summaries
+}
+
+// joinTeams joins the team names of rows with ", "
+func joinTeams(rows []dbUtil.TeamAccuracy) string {
+	teams := make([]string, len(rows))
+	for i, row := range rows {
+		teams[i] = row.Team
+	}
+	return strings.Join(teams, ", ")
+}
+
+// teamSummaryTable lays out summaries for output.Write. Users without a
+// qualifying team get empty cells.
+func teamSummaryTable(summaries []TeamSummary) output.Table {
+	t := output.Table{Columns: []string{
+		"userID", "userEmail", "bestTeam", "bestAccuracy", "worstTeam", "worstAccuracy",
+	}}
+	for _, s := range summaries {
+		var bestTeam, bestAccuracy, worstTeam, worstAccuracy interface{}
+		if s.BestTeam != "" {
+			bestTeam, bestAccuracy = s.BestTeam, s.BestAccuracy
+			worstTeam, worstAccuracy = s.WorstTeam, s.WorstAccuracy
+		}
+		t.Append(s.UserID, s.UserEmail, bestTeam, bestAccuracy, worstTeam, worstAccuracy)
+	}
+	return t
+}
+
+// teamAccuracyTable lays out the full matrix for output.Write, marking the
+// rows with enough picks to be a best or worst team
+func teamAccuracyTable(rows []dbUtil.TeamAccuracy, minPicks int) output.Table {
+	t := output.Table{Columns: []string{"userID", "team", "picks", "correct", "accuracy", "qualifies"}}
+	for _, row := range rows {
+		t.Append(row.UserID, row.Team, row.Picks, row.Correct, row.Accuracy, row.Picks >= minPicks)
+	}
+	return t
+}
+
+// teamNames maps the dbUtil.TeamKey of every team in games to its name as
+// the games spell it, trimmed
+func teamNames(games []dbUtil.Game) map[string]string {
+	names := make(map[string]string)
+	for _, g := range games {
+		for _, team := range []string{g.HomeTeam, g.AwayTeam} {
+			if key := dbUtil.TeamKey(team); key != "" {
+				if _, ok := names[key]; !ok {
+					names[key] = strings.TrimSpace(team)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// teamName returns team as names spells it, or trimmed when no game has it
+func teamName(names map[string]string, team string) string {
+	if name, ok := names[dbUtil.TeamKey(team)]; ok {
+		return name
+	}
+	return strings.TrimSpace(team)
+}
@@ -0,0 +1,37 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+func TestTeamAccuracyNormalisesTeams(t *testing.T) {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2425", Week: 1, Scored: true, HomeTeam: "Jets ", AwayTeam: "Bills"},
+		{ID: "2", Season: "2425", Week: 2, Scored: true, HomeTeam: "Bills", AwayTeam: "jets"},
+	}
+	store.PickRows = []dbUtil.Pick{
+		{UID: "1", Season: "2425", Week: 1, GameID: "1", Team: "Jets", Correct: true, Graded: true},
+		{UID: "1", Season: "2425", Week: 2, GameID: "2", Team: " JETS", Graded: true},
+		{UID: "1", Season: "2425", Week: 2, GameID: "3", Team: "Dolphins", Correct: true, Graded: true},
+		{UID: "2", Season: "2425", Week: 1, GameID: "1", Team: "bills ", Graded: true},
+		{UID: "2", Season: "2425", Week: 2, GameID: "2", Team: " "},
+	}
+
+	got, err := TeamAccuracy(context.Background(), store, "2425")
+	if err != nil {
+		t.Fatalf("TeamAccuracy: %v", err)
+	}
+	want := []dbUtil.TeamAccuracy{
+		{Scope: "2425", UserID: "1", Team: "Dolphins", Picks: 1, Correct: 1, Accuracy: 100},
+		{Scope: "2425", UserID: "1", Team: "Jets", Picks: 2, Correct: 1, Accuracy: 50},
+		{Scope: "2425", UserID: "2", Team: "Bills", Picks: 1, Correct: 0, Accuracy: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TeamAccuracy\n got: %+v\nwant: %+v", got, want)
+	}
+}
@@ -1,14 +1,13 @@
 package userStats
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"log"
 
-	"github.com/jimdaga/pickemcli/internal/db"
 	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // TopPicked represents the topPicked command
@@ -17,168 +16,65 @@ var TopPicked = &cobra.Command{
 	Short: "Generate top pick analytics",
 	Long: `Top Picks Data Generation
 			Generate various analytics based on users top picks`,
-	Run: func(cmd *cobra.Command, args []string) {
-		database := db.Connect()
-		defer database.Close()
-
-		RunTopPicked(database)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCommand(cmd, RunTopPicked)
 	},
 }
 
-// RunTopPicked executes the top picked teams analysis
-func RunTopPicked(db *sql.DB) {
-	fmt.Println("..| Most Picked Team(s) by UID |..")
-	TopPickedByUid(db)
+func init() {
+	addSeasonFlags(TopPicked)
 }
 
-func TopPickedByUid(db *sql.DB) {
-	currentSeason := viper.GetString("app.season.current")
+// RunTopPicked executes the top picked teams analysis
+func RunTopPicked(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error) {
+	log.Println("..| Most Picked Team(s) by UID |..")
+	return TopPickedByUid(ctx, store, current)
+}
 
-	uidrows, err := db.Query("SELECT DISTINCT(uid) FROM public.pickem_api_gamepicks")
+// TopPickedByUid finds each user's most picked team(s), all time and for the
+// current season
+func TopPickedByUid(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error) {
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{})
 	if err != nil {
-		log.Printf("Error getting distinct UIDs: %v", err)
-		return
+		return nil, fmt.Errorf("error getting picks: %w", err)
 	}
-	defer uidrows.Close()
-
-	uids := make([]string, 0)
-	for uidrows.Next() {
-		var uid string
-		if err := uidrows.Scan(&uid); err != nil {
-			log.Printf("Error scanning UID: %v", err)
-			continue
-		}
-		uids = append(uids, uid)
+	emails, err := store.UserEmails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user emails: %w", err)
 	}
 
-	for _, uid := range uids {
-		// Get user email
-		userEmail, err := dbUtil.GetUserEmail(db, uid)
-		if err != nil {
-			log.Printf("Error getting email for UID %s: %v", uid, err)
-			continue
-		}
+	allStats := topPickedStats(picks, emails, current.String())
 
-		// Create user stats object
-		stats := dbUtil.NewUserStats(uid, userEmail)
+	// Upsert the user stats
+	if err := store.UpsertUserStats(ctx, allStats); err != nil {
+		return nil, fmt.Errorf("error upserting user stats: %w", err)
+	}
+	return allStats, nil
+}
 
-		// Find the most picked team for all time
-		allTimeRows, err := db.Query("SELECT uid, pick, COUNT(*) as count "+
-			"FROM pickem_api_gamepicks "+
-			"WHERE uid = $1 "+
-			"GROUP BY uid, pick "+
-			"HAVING COUNT(*) = (SELECT MAX(c) FROM (SELECT COUNT(*) as c FROM pickem_api_gamepicks WHERE uid = $1 GROUP BY uid, pick) subquery) "+
-			"ORDER BY count DESC", uid)
+// topPickedStats sets MostPickedTotal and MostPickedSeason for every user
+// with a pick. Ties are joined with ", ".
+func topPickedStats(picks []dbUtil.Pick, emails map[string]string, season string) []*dbUtil.UserStats {
+	total, seasonal := teamPickCounts(picks, season)
 
-		if err != nil {
-			log.Printf("Error getting all-time most picked for UID %s: %v", uid, err)
-			continue
-		}
+	var allStats []*dbUtil.UserStats
+	for _, uid := range sortedKeys(total) {
+		stats := dbUtil.NewUserStats(uid, dbUtil.EmailFor(emails, uid))
 
-		// Collect all the most picked teams (handle ties)
-		var mostPickedTeams []string
-		var maxCount int
-		for allTimeRows.Next() {
-			var uidResult string
-			var pick string
-			var count int
-			err := allTimeRows.Scan(&uidResult, &pick, &count)
-			if err != nil {
-				log.Printf("Error scanning all-time result for UID %s: %v", uid, err)
-				continue
-			}
-			mostPickedTeams = append(mostPickedTeams, pick)
-			maxCount = count
-		}
-		allTimeRows.Close()
+		teams, maxCount := pickedTeams(total[uid], true)
+		stats.MostPickedTotal = dbUtil.StringPtr(teams)
 
-		// Set the most picked team(s) for all time
-		if len(mostPickedTeams) > 0 {
-			// If multiple teams tied, join them with commas
-			var mostPickedString string
-			if len(mostPickedTeams) == 1 {
-				mostPickedString = mostPickedTeams[0]
-			} else {
-				// Handle ties by joining team names
-				mostPickedString = ""
-				for i, team := range mostPickedTeams {
-					if i > 0 {
-						mostPickedString += ", "
-					}
-					mostPickedString += team
-				}
-			}
-			stats.MostPickedTotal = dbUtil.StringPtr(mostPickedString)
+		var seasonMaxCount int
+		if counts, ok := seasonal[uid]; ok {
+			var seasonTeams string
+			seasonTeams, seasonMaxCount = pickedTeams(counts, true)
+			stats.MostPickedSeason = dbUtil.StringPtr(seasonTeams)
 		}
 
-		// Find the most picked team for current season
-		seasonRows, err := db.Query("SELECT uid, pick, COUNT(*) as count "+
-			"FROM pickem_api_gamepicks "+
-			"WHERE uid = $1 AND gameseason = $2 "+
-			"GROUP BY uid, pick "+
-			"HAVING COUNT(*) = (SELECT MAX(c) FROM (SELECT COUNT(*) as c FROM pickem_api_gamepicks WHERE uid = $1 AND gameseason = $2 GROUP BY uid, pick) subquery) "+
-			"ORDER BY count DESC", uid, currentSeason)
-
-		if err != nil {
-			log.Printf("Error getting season most picked for UID %s: %v", uid, err)
-			// Continue with just all-time data
-		} else {
-			// Collect season most picked teams (handle ties)
-			var seasonMostPickedTeams []string
-			var seasonMaxCount int
-			for seasonRows.Next() {
-				var uidResult string
-				var pick string
-				var count int
-				err := seasonRows.Scan(&uidResult, &pick, &count)
-				if err != nil {
-					log.Printf("Error scanning season result for UID %s: %v", uid, err)
-					continue
-				}
-				seasonMostPickedTeams = append(seasonMostPickedTeams, pick)
-				seasonMaxCount = count
-			}
-			seasonRows.Close()
+		log.Printf("✓ UID: %s, Most Picked - Total: %s (%d picks), Season: %s (%d picks)",
+			uid, teams, maxCount, stringOrNone(stats.MostPickedSeason), seasonMaxCount)
 
-			// Set the most picked team(s) for current season
-			if len(seasonMostPickedTeams) > 0 {
-				var seasonMostPickedString string
-				if len(seasonMostPickedTeams) == 1 {
-					seasonMostPickedString = seasonMostPickedTeams[0]
-				} else {
-					// Handle ties by joining team names
-					seasonMostPickedString = ""
-					for i, team := range seasonMostPickedTeams {
-						if i > 0 {
-							seasonMostPickedString += ", "
-						}
-						seasonMostPickedString += team
-					}
-				}
-				stats.MostPickedSeason = dbUtil.StringPtr(seasonMostPickedString)
-			}
-
-			log.Printf("✓ UID: %s, Most Picked - Total: %s (%d picks), Season: %s (%d picks)",
-				uid,
-				func() string {
-					if stats.MostPickedTotal != nil {
-						return *stats.MostPickedTotal
-					}
-					return "none"
-				}(),
-				maxCount,
-				func() string {
-					if stats.MostPickedSeason != nil {
-						return *stats.MostPickedSeason
-					}
-					return "none"
-				}(),
-				seasonMaxCount)
-		}
-
-		// Upsert the user stats
-		if err := dbUtil.UpsertUserStats(db, stats); err != nil {
-			log.Printf("Error upserting user stats for UID %s: %v", uid, err)
-		}
+		allStats = append(allStats, stats)
 	}
+	return allStats
 }
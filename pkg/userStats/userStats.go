@@ -1,28 +1,36 @@
 package userStats
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+func init() {
+	addSeasonFlags(UserStats)
+}
+
 // UserStats represents the main userStats command
 var UserStats = &cobra.Command{
 	Use:   "userStats",
 	Short: "Generate user statistics and analytics",
 	Long: `User Statistics Generation
 			Generate various analytics based on user picks including:
-			- Pick accuracy statistics  
+			- Pick accuracy statistics
 			- Most and least picked teams
 			- Weekly wins tracking`,
-	Run: func(cmd *cobra.Command, args []string) {
-		database := db.Connect()
-		defer database.Close()
-
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Run all user statistics operations
-		RunPickStats(database)
-		RunTopPicked(database) 
-		RunLeastPicked(database)
+		return runCommand(cmd, allCollectors...)
 	},
 }
 
@@ -31,7 +39,171 @@ func AllStats() *cobra.Command {
 	return UserStats
 }
 
-func init() {
-	// Set configuration defaults
-	viper.SetDefault("app.season.current", "2425")
+// collector is the signature shared by the Run* functions. It returns the
+// per-user records it wrote.
+type collector func(ctx context.Context, store dbUtil.Store, current season.Season) ([]*dbUtil.UserStats, error)
+
+// allCollectors is every user statistics collector, in the order they run
+var allCollectors = []collector{RunPickStats, RunTopPicked, RunLeastPicked}
+
+// RunAll runs every user statistics collector for the current season,
+// recording the season's results in the by-season history as well, and
+// then updates the season and all-time leaderboards, streaks and team
+// accuracy, and the current season's game consensus. The Django tables
+// are read once for the whole run.
+func RunAll(ctx context.Context, store dbUtil.Store) error {
+	store = dbUtil.NewCachedStore(store)
+	current, err := season.Current(ctx, store)
+	if err != nil {
+		return err
+	}
+	_, err = runCollectors(ctx, &seasonHistoryStore{Store: store, season: current}, current, allCollectors)
+	if ctx.Err() != nil || db.IsConnectionError(err) {
+		return err
+	}
+	return errors.Join(err,
+		UpdateLeaderboards(ctx, store, current),
+		UpdateStreaks(ctx, store, current),
+		UpdateTeamAccuracy(ctx, store, current),
+		UpdateConsensus(ctx, store, current))
+}
+
+// runCollectors runs collectors for one season and returns their records
+// merged per user. A failing collector does not stop the others unless the
+// database connection itself has gone, and all errors are returned together.
+func runCollectors(ctx context.Context, store dbUtil.Store, current season.Season, collectors []collector) ([]*dbUtil.UserStats, error) {
+	var results [][]*dbUtil.UserStats
+	var errs []error
+	for _, run := range collectors {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		stats, err := run(ctx, store, current)
+		results = append(results, stats)
+		if err == nil {
+			continue
+		}
+		errs = append(errs, err)
+		if db.IsConnectionError(err) {
+			break
+		}
+	}
+	return mergeResults(results...), errors.Join(errs...)
+}
+
+// mergeResults merges the records of several collectors into one per
+// user, ordered by user ID
+func mergeResults(results ...[]*dbUtil.UserStats) []*dbUtil.UserStats {
+	byUID := make(map[string]*dbUtil.UserStats)
+	for _, batch := range results {
+		for _, stats := range batch {
+			if merged, ok := byUID[stats.UserID]; ok {
+				merged.Merge(stats)
+				continue
+			}
+			copied := *stats
+			byUID[stats.UserID] = &copied
+		}
+	}
+
+	merged := make([]*dbUtil.UserStats, 0, len(byUID))
+	for _, uid := range sortedKeys(byUID) {
+		merged = append(merged, byUID[uid])
+	}
+	return merged
+}
+
+// openStore returns the store a command works through. With dry_run set
+// its reads run in one read-only transaction and its writes are held
+// back. The caller must call done when finished with the store.
+func openStore(ctx context.Context, database *sql.DB) (store dbUtil.Store, done func(), err error) {
+	if !viper.GetBool("dry_run") {
+		return dbUtil.NewPostgresStore(database), func() {}, nil
+	}
+	readOnly, tx, err := dbUtil.NewReadOnlyStore(ctx, database)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dbUtil.NewDryRunStore(readOnly), func() { tx.Rollback() }, nil
+}
+
+// parseScope normalises a --season value: a season code, allTime, or ""
+// for the current season
+func parseScope(scope, allTime string) (string, error) {
+	if scope == "" || strings.EqualFold(scope, allTime) {
+		return strings.ToLower(scope), nil
+	}
+	s, err := season.Parse(scope)
+	if err != nil {
+		return "", fmt.Errorf("--season: %w", err)
+	}
+	return s.String(), nil
+}
+
+// resolveScope returns scope, or the current season's code when scope is ""
+func resolveScope(ctx context.Context, store dbUtil.Store, scope string) (string, error) {
+	if scope != "" {
+		return scope, nil
+	}
+	current, err := season.Current(ctx, store)
+	if err != nil {
+		return "", err
+	}
+	return current.String(), nil
+}
+
+// teamPickCounts counts picks per user and team, all time and for season
+func teamPickCounts(picks []dbUtil.Pick, season string) (total, seasonal map[string]map[string]int) {
+	total = make(map[string]map[string]int)
+	seasonal = make(map[string]map[string]int)
+	for _, p := range picks {
+		if total[p.UID] == nil {
+			total[p.UID] = make(map[string]int)
+		}
+		total[p.UID][p.Team]++
+
+		if p.Season == season {
+			if seasonal[p.UID] == nil {
+				seasonal[p.UID] = make(map[string]int)
+			}
+			seasonal[p.UID][p.Team]++
+		}
+	}
+	return total, seasonal
+}
+
+// pickedTeams returns the team(s) with the highest (most) or lowest count,
+// joined with ", " in name order, along with that count
+func pickedTeams(counts map[string]int, most bool) (string, int) {
+	var teams []string
+	var best int
+	for _, team := range sortedKeys(counts) {
+		count := counts[team]
+		switch {
+		case len(teams) == 0, most && count > best, !most && count < best:
+			teams = []string{team}
+			best = count
+		case count == best:
+			teams = append(teams, team)
+		}
+	}
+	return strings.Join(teams, ", "), best
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringOrNone(s *string) string {
+	if s != nil {
+		return *s
+	}
+	return "none"
 }
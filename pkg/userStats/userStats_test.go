@@ -0,0 +1,253 @@
+package userStats
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/viper"
+)
+
+// fixtureStore returns a MemoryStore holding two seasons, 2324 and the
+// current 2425, with users covering a perfect week, a tied week win, a
+// user whose picks are all ungraded and a user with tied team counts.
+//
+// Games 1, 10 and 12 were won by A, games 2 and 11 by C; game 13 is not
+// scored yet.
+func fixtureStore() *dbUtil.MemoryStore {
+	store := dbUtil.NewMemoryStore()
+	store.GameRows = []dbUtil.Game{
+		{ID: "1", Season: "2324", Week: 1, Scored: true, HomeTeam: "A", AwayTeam: "B"},
+		{ID: "2", Season: "2324", Week: 1, Scored: true, HomeTeam: "C", AwayTeam: "D"},
+		{ID: "10", Season: "2425", Week: 1, Scored: true, HomeTeam: "A", AwayTeam: "B"},
+		{ID: "11", Season: "2425", Week: 1, Scored: true, HomeTeam: "C", AwayTeam: "D"},
+		{ID: "12", Season: "2425", Week: 2, Scored: true, HomeTeam: "A", AwayTeam: "C"},
+		{ID: "13", Season: "2425", Week: 2, HomeTeam: "B", AwayTeam: "D"},
+	}
+	store.PickRows = []dbUtil.Pick{
+		// 1: every game picked, perfect in 2324 week 1 and 2425 week 2
+		{UID: "1", Season: "2324", Week: 1, GameID: "1", Team: "A", Correct: true, Graded: true},
+		{UID: "1", Season: "2324", Week: 1, GameID: "2", Team: "C", Correct: true, Graded: true},
+		{UID: "1", Season: "2425", Week: 1, GameID: "10", Team: "A", Correct: true, Graded: true},
+		{UID: "1", Season: "2425", Week: 1, GameID: "11", Team: "D", Graded: true},
+		{UID: "1", Season: "2425", Week: 2, GameID: "12", Team: "A", Correct: true, Graded: true},
+		// 2: current season only, one ungraded pick
+		{UID: "2", Season: "2425", Week: 1, GameID: "10", Team: "B", Graded: true},
+		{UID: "2", Season: "2425", Week: 1, GameID: "11", Team: "C", Correct: true, Graded: true},
+		{UID: "2", Season: "2425", Week: 2, GameID: "13", Team: "B"},
+		// 3: no graded picks
+		{UID: "3", Season: "2425", Week: 2, GameID: "13", Team: "D"},
+		// 4: last season only, one pick each for B and C
+		{UID: "4", Season: "2324", Week: 1, GameID: "1", Team: "B", Graded: true},
+		{UID: "4", Season: "2324", Week: 1, GameID: "2", Team: "C", Correct: true, Graded: true},
+	}
+	store.SeasonPointsRows = []dbUtil.SeasonPoints{
+		{UserID: "1", Season: "2324", WeekWinner: weeksWon(1), YearWinner: true},
+		{UserID: "1", Season: "2425", WeekWinner: weeksWon(1)},
+		{UserID: "2", Season: "2425", WeekWinner: weeksWon(1)},
+		{UserID: "4", Season: "2324"},
+	}
+	store.Emails["1"] = "one@example.com"
+	return store
+}
+
+// weeksWon returns week winner flags with the given weeks set
+func weeksWon(weeks ...int) [dbUtil.SeasonWeeks]bool {
+	var flags [dbUtil.SeasonWeeks]bool
+	for _, w := range weeks {
+		flags[w-1] = true
+	}
+	return flags
+}
+
+// fixtureStats is every field the collectors write for fixtureStore's
+// users, keyed by user ID and pickem_api_userstats column
+var fixtureStats = map[string]map[string]interface{}{
+	"1": {
+		"userEmail":      "one@example.com",
+		"weeksWonSeason": 1, "weeksWonTotal": 2,
+		"pickPercentSeason": 66, "pickPercentTotal": 80,
+		"correctPickTotalSeason": 2, "correctPickTotalTotal": 4,
+		"totalPicksSeason": 3, "totalPicksTotal": 5,
+		"mostPickedSeason": "A", "mostPickedTotal": "A",
+		"leastPickedSeason": "D", "leastPickedTotal": "C, D",
+		"seasonsWon":        1,
+		"missedPicksSeason": 0, "missedPicksTotal": 0,
+		"perfectWeeksSeason": 1, "perfectWeeksTotal": 2,
+	},
+	"2": {
+		"userEmail":      "user-2@placeholder.local",
+		"weeksWonSeason": 1, "weeksWonTotal": 1,
+		"pickPercentSeason": 33, "pickPercentTotal": 33,
+		"correctPickTotalSeason": 1, "correctPickTotalTotal": 1,
+		"totalPicksSeason": 3, "totalPicksTotal": 3,
+		"mostPickedSeason": "B", "mostPickedTotal": "B",
+		"leastPickedSeason": "C", "leastPickedTotal": "C",
+		"seasonsWon":        0,
+		"missedPicksSeason": 1, "missedPicksTotal": 3,
+		"perfectWeeksSeason": 0, "perfectWeeksTotal": 0,
+	},
+	"3": {
+		"userEmail":      "user-3@placeholder.local",
+		"weeksWonSeason": 0, "weeksWonTotal": 0,
+		"pickPercentSeason": 0, "pickPercentTotal": 0,
+		"correctPickTotalSeason": 0, "correctPickTotalTotal": 0,
+		"totalPicksSeason": 1, "totalPicksTotal": 1,
+		"mostPickedSeason": "D", "mostPickedTotal": "D",
+		"leastPickedSeason": "D", "leastPickedTotal": "D",
+		"seasonsWon":        0,
+		"missedPicksSeason": 3, "missedPicksTotal": 5,
+		"perfectWeeksSeason": 0, "perfectWeeksTotal": 0,
+	},
+	"4": {
+		"userEmail":      "user-4@placeholder.local",
+		"weeksWonSeason": 0, "weeksWonTotal": 0,
+		"pickPercentSeason": 0, "pickPercentTotal": 50,
+		"correctPickTotalSeason": 0, "correctPickTotalTotal": 1,
+		"totalPicksSeason": 0, "totalPicksTotal": 2,
+		"mostPickedSeason": nil, "mostPickedTotal": "B, C",
+		"leastPickedSeason": nil, "leastPickedTotal": "B, C",
+		"seasonsWon":        0,
+		"missedPicksSeason": 3, "missedPicksTotal": 3,
+		"perfectWeeksSeason": 0, "perfectWeeksTotal": 0,
+	},
+}
+
+// fixtureFields returns fixtureStats with only the email and the given
+// columns set; the others are nil, as a collector leaves them
+func fixtureFields(columns ...string) map[string]map[string]interface{} {
+	want := make(map[string]map[string]interface{}, len(fixtureStats))
+	for uid, all := range fixtureStats {
+		row := fields(dbUtil.NewUserStats(uid, ""))
+		row["userEmail"] = all["userEmail"]
+		for _, column := range columns {
+			row[column] = all[column]
+		}
+		want[uid] = row
+	}
+	return want
+}
+
+var (
+	correctPickColumns = []string{
+		"pickPercentSeason", "pickPercentTotal",
+		"correctPickTotalSeason", "correctPickTotalTotal",
+		"totalPicksSeason", "totalPicksTotal",
+	}
+	weeksWonColumns = []string{
+		"weeksWonSeason", "weeksWonTotal", "seasonsWon",
+		"missedPicksSeason", "missedPicksTotal",
+		"perfectWeeksSeason", "perfectWeeksTotal",
+	}
+	allColumns = append(append(append([]string{}, correctPickColumns...), weeksWonColumns...),
+		"mostPickedSeason", "mostPickedTotal", "leastPickedSeason", "leastPickedTotal")
+)
+
+func TestCollectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		collectors []collector
+		want       map[string]map[string]interface{}
+	}{
+		{
+			name:       "correct picks",
+			collectors: []collector{CorrectPicksByUid},
+			want:       fixtureFields(correctPickColumns...),
+		},
+		{
+			name:       "weeks won",
+			collectors: []collector{WeeksWonByUid},
+			want:       fixtureFields(weeksWonColumns...),
+		},
+		{
+			name:       "pick stats",
+			collectors: []collector{RunPickStats},
+			want:       fixtureFields(append(append([]string{}, correctPickColumns...), weeksWonColumns...)...),
+		},
+		{
+			name:       "top picked",
+			collectors: []collector{RunTopPicked},
+			want:       fixtureFields("mostPickedSeason", "mostPickedTotal"),
+		},
+		{
+			name:       "least picked",
+			collectors: []collector{RunLeastPicked},
+			want:       fixtureFields("leastPickedSeason", "leastPickedTotal"),
+		},
+		{
+			name:       "all",
+			collectors: allCollectors,
+			want:       fixtureFields(allColumns...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fixtureStore()
+			got, err := runCollectors(context.Background(), store, mustSeason(t, "2425"), tt.collectors)
+			if err != nil {
+				t.Fatalf("runCollectors: %v", err)
+			}
+			if !reflect.DeepEqual(byUser(got), tt.want) {
+				t.Errorf("returned records\n got: %v\nwant: %v", byUser(got), tt.want)
+			}
+			if !reflect.DeepEqual(byUser(storedStats(store)), tt.want) {
+				t.Errorf("stored records\n got: %v\nwant: %v", byUser(storedStats(store)), tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	settings := map[string]interface{}{
+		"app.season.current":        "auto",
+		"leaderboard.rank_by":       "weeks_won",
+		"leaderboard.tie_breakers":  "correct_picks,pick_percent,perfect_weeks",
+		"team_accuracy.min_picks":   5,
+		"consensus.upset_threshold": 25,
+	}
+	for key, value := range settings {
+		viper.Set(key, value)
+		defer viper.Set(key, nil)
+	}
+
+	store := fixtureStore()
+	if err := RunAll(context.Background(), store); err != nil {
+		t.Fatalf("RunAll: %v", err)
+	}
+	want := fixtureFields(allColumns...)
+	if got := byUser(storedStats(store)); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored records\n got: %v\nwant: %v", got, want)
+	}
+
+	// Only users with a current season pick get a by-season history row
+	for uid, want := range map[string]bool{"1": true, "2": true, "3": true, "4": false} {
+		if _, got := store.SeasonStats[dbUtil.SeasonStatsKey(uid, "2425")]; got != want {
+			t.Errorf("2425 history row for userID %s: got %v, want %v", uid, got, want)
+		}
+	}
+}
+
+func TestBackfillHistoryRows(t *testing.T) {
+	store := fixtureStore()
+	if _, err := runSeasons(context.Background(), store, []season.Season{mustSeason(t, "2324")}, allCollectors); err != nil {
+		t.Fatalf("runSeasons: %v", err)
+	}
+
+	// Backfilling leaves pickem_api_userstats alone and only writes
+	// history rows for users who picked in the season
+	if len(store.Stats) != 0 {
+		t.Errorf("backfill wrote %d pickem_api_userstats records", len(store.Stats))
+	}
+	var got []string
+	for key := range store.SeasonStats {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	if want := []string{"1/2324", "4/2324"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history rows %v, want %v", got, want)
+	}
+}
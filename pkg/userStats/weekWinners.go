@@ -0,0 +1,347 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/jimdaga/pickemcli/internal/config"
+	"github.com/jimdaga/pickemcli/internal/db"
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+	"github.com/jimdaga/pickemcli/pkg/output"
+	"github.com/jimdaga/pickemcli/pkg/season"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Week winner tie-break policies
+const (
+	// TieShare makes every user tied on correct picks a winner
+	TieShare = "share"
+	// TieLatestCorrect gives the win to the tied user whose most recent
+	// correct pick came latest, comparing earlier picks while still tied
+	TieLatestCorrect = "latest_correct"
+)
+
+// CheckTieBreak returns an error if policy is not a week winner policy
+func CheckTieBreak(policy string) error {
+	if policy != TieShare && policy != TieLatestCorrect {
+		return fmt.Errorf("unknown tie-break policy %q (use %s or %s)", policy, TieShare, TieLatestCorrect)
+	}
+	return nil
+}
+
+// WeekWinnerCheck compares the computed and stored winner flag of one user
+// for one week. Stored is nil when the user has no
+// pickem_api_userseasonpoints row for the season.
+type WeekWinnerCheck struct {
+	Season   string
+	Week     int
+	UserID   string
+	Correct  int
+	Computed bool
+	Stored   *bool
+}
+
+// Agrees reports whether the stored flag matches the computed one. A
+// missing row agrees with a computed loss.
+func (c WeekWinnerCheck) Agrees() bool {
+	if c.Stored == nil {
+		return !c.Computed
+	}
+	return *c.Stored == c.Computed
+}
+
+// WeekWinners represents the weekWinners command
+var WeekWinners = &cobra.Command{
+	Use:   "weekWinners",
+	Short: "Compute weekly winners from picks and check week_N_winner",
+	Long: `Weekly Winner Calculation
+			For every fully scored week, find the user(s) with the most correct picks
+			and report where pickem_api_userseasonpoints disagrees, or fix the
+			week_N_winner flags with --apply`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy := viper.GetString("week_winners.tie_break")
+		if err := CheckTieBreak(policy); err != nil {
+			return fmt.Errorf("week_winners.tie_break: %w", err)
+		}
+		seasonCode, _ := cmd.Flags().GetString("season")
+		if seasonCode != "" {
+			s, err := season.Parse(seasonCode)
+			if err != nil {
+				return fmt.Errorf("--season: %w", err)
+			}
+			seasonCode = s.String()
+		}
+		apply, _ := cmd.Flags().GetBool("apply")
+		if err := output.Check(output.Format()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		database, err := db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		store := dbUtil.NewPostgresStore(database)
+		checks, err := CheckWeekWinners(ctx, store, seasonCode, policy)
+		if err != nil {
+			return err
+		}
+		disagreements := weekWinnerDisagreements(checks)
+		if err := output.Write(cmd.OutOrStdout(), output.Format(), weekWinnersTable(disagreements)); err != nil {
+			return err
+		}
+		log.Printf("%d of %d week winner flags disagree with the picks", len(disagreements), len(checks))
+
+		if !apply || len(disagreements) == 0 {
+			return nil
+		}
+		if viper.GetBool("dry_run") {
+			log.Printf("Dry run: not applying")
+			return nil
+		}
+		return ApplyWeekWinners(ctx, store, disagreements)
+	},
+}
+
+func init() {
+	WeekWinners.Flags().String("season", "", "Only check this season (YYZZ) (default: every season)")
+	WeekWinners.Flags().Bool("apply", false, "Write the computed week_N_winner flags where they disagree")
+	config.RegisterCheck("week_winners.tie_break", CheckTieBreak)
+}
+
+// CheckWeekWinners computes the winners of every fully scored week of
+// seasonCode (or of every season when empty) and compares them with the
+// week_N_winner flags. It returns a check for every user who picked in or
+// is flagged as winning such a week, ordered by season, week and user.
+func CheckWeekWinners(ctx context.Context, store dbUtil.Store, seasonCode, policy string) ([]WeekWinnerCheck, error) {
+	if err := CheckTieBreak(policy); err != nil {
+		return nil, err
+	}
+	picks, err := store.Picks(ctx, dbUtil.PickFilter{Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting picks: %w", err)
+	}
+	games, err := store.Games(ctx, dbUtil.GameFilter{Season: seasonCode})
+	if err != nil {
+		return nil, fmt.Errorf("error getting games: %w", err)
+	}
+	points, err := store.SeasonPoints(ctx, seasonCode)
+	if err != nil {
+		return nil, fmt.Errorf("error getting season points: %w", err)
+	}
+	return weekWinnerChecks(picks, games, points, policy), nil
+}
+
+// ApplyWeekWinners writes the computed flag of every check that disagrees
+// and has a season points row to write to
+func ApplyWeekWinners(ctx context.Context, store dbUtil.Store, checks []WeekWinnerCheck) error {
+	var updates []dbUtil.WeekWinnerUpdate
+	for _, c := range checks {
+		if c.Agrees() {
+			continue
+		}
+		if c.Stored == nil {
+			log.Printf("Not applying week %d winner for userID %s: no season %s points row", c.Week, c.UserID, c.Season)
+			continue
+		}
+		updates = append(updates, dbUtil.WeekWinnerUpdate{UserID: c.UserID, Season: c.Season, Week: c.Week, Winner: c.Computed})
+	}
+	return store.UpdateWeekWinners(ctx, updates)
+}
+
+// weekWinnerChecks does the work of CheckWeekWinners
+func weekWinnerChecks(picks []dbUtil.Pick, games []dbUtil.Game, points []dbUtil.SeasonPoints, policy string) []WeekWinnerCheck {
+	// A week counts once every one of its games is scored
+	type weekGames struct{ total, scored int }
+	weeks := make(map[seasonWeek]*weekGames)
+	gamesByID := make(map[string]dbUtil.Game, len(games))
+	for _, g := range games {
+		gamesByID[g.ID] = g
+		key := seasonWeek{g.Season, g.Week}
+		if weeks[key] == nil {
+			weeks[key] = &weekGames{}
+		}
+		weeks[key].total++
+		if g.Scored {
+			weeks[key].scored++
+		}
+	}
+
+	// Each user's correct picks per scored week, as games
+	correct := make(map[seasonWeek]map[string][]dbUtil.Game)
+	for _, p := range picks {
+		key := seasonWeek{p.Season, p.Week}
+		w := weeks[key]
+		if w == nil || w.scored != w.total || key.week < 1 || key.week > dbUtil.SeasonWeeks {
+			continue
+		}
+		if correct[key] == nil {
+			correct[key] = make(map[string][]dbUtil.Game)
+		}
+		if _, ok := correct[key][p.UID]; !ok {
+			correct[key][p.UID] = nil
+		}
+		if g, ok := gamesByID[p.GameID]; ok && p.Correct {
+			correct[key][p.UID] = append(correct[key][p.UID], g)
+		}
+	}
+
+	stored := make(map[string]*dbUtil.SeasonPoints)
+	for i := range points {
+		stored[points[i].UserID+"/"+points[i].Season] = &points[i]
+	}
+
+	var checks []WeekWinnerCheck
+	for key, w := range weeks {
+		if w.scored != w.total || key.week < 1 || key.week > dbUtil.SeasonWeeks {
+			continue
+		}
+		winners := weekWinners(correct[key], policy)
+
+		// Everyone who picked, plus anyone flagged without picking
+		users := make(map[string]bool)
+		for uid := range correct[key] {
+			users[uid] = true
+		}
+		for _, sp := range points {
+			if sp.Season == key.season && sp.WeekWinner[key.week-1] {
+				users[sp.UserID] = true
+			}
+		}
+
+		for uid := range users {
+			c := WeekWinnerCheck{
+				Season:   key.season,
+				Week:     key.week,
+				UserID:   uid,
+				Correct:  len(correct[key][uid]),
+				Computed: winners[uid],
+			}
+			if sp, ok := stored[uid+"/"+key.season]; ok {
+				won := sp.WeekWinner[key.week-1]
+				c.Stored = &won
+			}
+			checks = append(checks, c)
+		}
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		a, b := checks[i], checks[j]
+		if a.Season != b.Season {
+			return seasonLess(a.Season, b.Season)
+		}
+		if a.Week != b.Week {
+			return a.Week < b.Week
+		}
+		return a.UserID < b.UserID
+	})
+	return checks
+}
+
+// weekWinners returns the users with the most correct picks, which must be
+// at least one, applying policy to ties
+func weekWinners(correct map[string][]dbUtil.Game, policy string) map[string]bool {
+	most := 0
+	for _, games := range correct {
+		if len(games) > most {
+			most = len(games)
+		}
+	}
+	winners := make(map[string]bool)
+	if most == 0 {
+		return winners
+	}
+
+	var tied []string
+	for _, uid := range sortedKeys(correct) {
+		if len(correct[uid]) == most {
+			tied = append(tied, uid)
+			// Most recent correct pick first
+			sort.Slice(correct[uid], func(i, j int) bool { return gameLess(correct[uid][j], correct[uid][i]) })
+		}
+	}
+
+	if policy == TieLatestCorrect && len(tied) > 1 {
+		// Compare the most recent correct picks, then the next most recent,
+		// keeping whoever has the latest game at each step
+		for i := 0; i < most && len(tied) > 1; i++ {
+			latest := correct[tied[0]][i]
+			for _, uid := range tied[1:] {
+				if gameLess(latest, correct[uid][i]) {
+					latest = correct[uid][i]
+				}
+			}
+			var still []string
+			for _, uid := range tied {
+				if !gameLess(correct[uid][i], latest) {
+					still = append(still, uid)
+				}
+			}
+			tied = still
+		}
+	}
+
+	for _, uid := range tied {
+		winners[uid] = true
+	}
+	return winners
+}
+
+// gameLess orders games by season, week and then game ID, numerically when
+// both IDs are numbers; there is no kickoff time to go by
+func gameLess(a, b dbUtil.Game) bool {
+	if a.Season != b.Season {
+		return seasonLess(a.Season, b.Season)
+	}
+	if a.Week != b.Week {
+		return a.Week < b.Week
+	}
+	ai, aErr := strconv.Atoi(a.ID)
+	bi, bErr := strconv.Atoi(b.ID)
+	if aErr == nil && bErr == nil {
+		return ai < bi
+	}
+	return a.ID < b.ID
+}
+
+// seasonLess orders two season codes chronologically, falling back to
+// string order for codes that do not parse
+func seasonLess(a, b string) bool {
+	sa, aErr := season.Parse(a)
+	sb, bErr := season.Parse(b)
+	if aErr != nil || bErr != nil {
+		return a < b
+	}
+	return sa.Before(sb)
+}
+
+// weekWinnerDisagreements returns the checks that disagree
+func weekWinnerDisagreements(checks []WeekWinnerCheck) []WeekWinnerCheck {
+	var disagree []WeekWinnerCheck
+	for _, c := range checks {
+		if !c.Agrees() {
+			disagree = append(disagree, c)
+		}
+	}
+	return disagree
+}
+
+// weekWinnersTable lays out checks for output.Write
+func weekWinnersTable(checks []WeekWinnerCheck) output.Table {
+	t := output.Table{Columns: []string{"season", "week", "userID", "correctPicks", "computed", "stored"}}
+	for _, c := range checks {
+		var stored interface{}
+		if c.Stored != nil {
+			stored = *c.Stored
+		}
+		t.Append(c.Season, c.Week, c.UserID, c.Correct, c.Computed, stored)
+	}
+	return t
+}
@@ -0,0 +1,151 @@
+package userStats
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jimdaga/pickemcli/internal/dbUtil"
+)
+
+// weekWinnerStore returns a MemoryStore for season 2425 where week 1 has
+// a clear winner, weeks 2 and 5 are tied on correct picks, week 3 is only
+// partly scored and week 4 is won by a user without a season points row.
+// User 3 is flagged as the week 1 winner without having picked.
+func weekWinnerStore() *dbUtil.MemoryStore {
+	store := dbUtil.NewMemoryStore()
+	for _, g := range []struct {
+		id     string
+		week   int
+		scored bool
+	}{
+		{"1", 1, true}, {"2", 1, true},
+		{"3", 2, true}, {"4", 2, true},
+		{"5", 3, true}, {"6", 3, false},
+		{"7", 4, true},
+		{"8", 5, true}, {"9", 5, true}, {"10", 5, true},
+	} {
+		store.GameRows = append(store.GameRows, dbUtil.Game{ID: g.id, Season: "2425", Week: g.week, Scored: g.scored})
+	}
+	pick := func(uid, gameID string, week int, correct bool) dbUtil.Pick {
+		return dbUtil.Pick{UID: uid, Season: "2425", Week: week, GameID: gameID, Correct: correct, Graded: true}
+	}
+	store.PickRows = []dbUtil.Pick{
+		pick("1", "1", 1, true), pick("1", "2", 1, true),
+		pick("2", "1", 1, false), pick("2", "2", 1, true),
+		// Tied on one correct pick; 2's came later
+		pick("1", "3", 2, true), pick("1", "4", 2, false),
+		pick("2", "3", 2, false), pick("2", "4", 2, true),
+		pick("1", "5", 3, true), pick("2", "5", 3, false),
+		pick("1", "7", 4, false), pick("4", "7", 4, true),
+		// Tied on two correct picks and on the latest; 2's earlier one
+		// came later
+		pick("1", "8", 5, true), pick("1", "10", 5, true),
+		pick("2", "9", 5, true), pick("2", "10", 5, true),
+	}
+	store.SeasonPointsRows = []dbUtil.SeasonPoints{
+		{UserID: "1", Season: "2425"},
+		{UserID: "2", Season: "2425", WeekWinner: weeksWon(1, 3)},
+		{UserID: "3", Season: "2425", WeekWinner: weeksWon(1)},
+	}
+	return store
+}
+
+func flag(b bool) *bool {
+	return &b
+}
+
+func TestCheckWeekWinners(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []WeekWinnerCheck
+	}{
+		{
+			policy: TieShare,
+			want: []WeekWinnerCheck{
+				{Season: "2425", Week: 1, UserID: "1", Correct: 2, Computed: true, Stored: flag(false)},
+				{Season: "2425", Week: 1, UserID: "2", Correct: 1, Stored: flag(true)},
+				{Season: "2425", Week: 1, UserID: "3", Stored: flag(true)},
+				{Season: "2425", Week: 2, UserID: "1", Correct: 1, Computed: true, Stored: flag(false)},
+				{Season: "2425", Week: 2, UserID: "2", Correct: 1, Computed: true, Stored: flag(false)},
+				{Season: "2425", Week: 4, UserID: "1", Stored: flag(false)},
+				{Season: "2425", Week: 4, UserID: "4", Correct: 1, Computed: true},
+				{Season: "2425", Week: 5, UserID: "1", Correct: 2, Computed: true, Stored: flag(false)},
+				{Season: "2425", Week: 5, UserID: "2", Correct: 2, Computed: true, Stored: flag(false)},
+			},
+		},
+		{
+			policy: TieLatestCorrect,
+			want: []WeekWinnerCheck{
+				{Season: "2425", Week: 1, UserID: "1", Correct: 2, Computed: true, Stored: flag(false)},
+				{Season: "2425", Week: 1, UserID: "2", Correct: 1, Stored: flag(true)},
+				{Season: "2425", Week: 1, UserID: "3", Stored: flag(true)},
+				{Season: "2425", Week: 2, UserID: "1", Correct: 1, Stored: flag(false)},
+				{Season: "2425", Week: 2, UserID: "2", Correct: 1, Computed: true, Stored: flag(false)},
+				{Season: "2425", Week: 4, UserID: "1", Stored: flag(false)},
+				{Season: "2425", Week: 4, UserID: "4", Correct: 1, Computed: true},
+				{Season: "2425", Week: 5, UserID: "1", Correct: 2, Stored: flag(false)},
+				{Season: "2425", Week: 5, UserID: "2", Correct: 2, Computed: true, Stored: flag(false)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			got, err := CheckWeekWinners(context.Background(), weekWinnerStore(), "2425", tt.policy)
+			if err != nil {
+				t.Fatalf("CheckWeekWinners: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckWeekWinners\n got: %s\nwant: %s", describeChecks(got), describeChecks(tt.want))
+			}
+		})
+	}
+}
+
+func TestCheckWeekWinnersRejectsUnknownPolicy(t *testing.T) {
+	if _, err := CheckWeekWinners(context.Background(), weekWinnerStore(), "2425", "first"); err == nil {
+		t.Error("CheckWeekWinners accepted tie-break \"first\"")
+	}
+}
+
+func TestApplyWeekWinners(t *testing.T) {
+	ctx := context.Background()
+	store := weekWinnerStore()
+	checks, err := CheckWeekWinners(ctx, store, "2425", TieShare)
+	if err != nil {
+		t.Fatalf("CheckWeekWinners: %v", err)
+	}
+	if err := ApplyWeekWinners(ctx, store, weekWinnerDisagreements(checks)); err != nil {
+		t.Fatalf("ApplyWeekWinners: %v", err)
+	}
+
+	// Only user 4, who has no row to write to, still disagrees
+	checks, err = CheckWeekWinners(ctx, store, "2425", TieShare)
+	if err != nil {
+		t.Fatalf("CheckWeekWinners: %v", err)
+	}
+	want := []WeekWinnerCheck{{Season: "2425", Week: 4, UserID: "4", Correct: 1, Computed: true}}
+	if got := weekWinnerDisagreements(checks); !reflect.DeepEqual(got, want) {
+		t.Errorf("disagreements after applying\n got: %s\nwant: %s", describeChecks(got), describeChecks(want))
+	}
+
+	// The partly scored week 3 is left alone
+	if !store.SeasonPointsRows[1].WeekWinner[2] {
+		t.Error("week 3 winner flag of userID 2 was cleared")
+	}
+}
+
+// describeChecks renders checks with their stored flags dereferenced
+func describeChecks(checks []WeekWinnerCheck) []string {
+	var out []string
+	for _, c := range checks {
+		stored := "none"
+		if c.Stored != nil {
+			stored = fmt.Sprint(*c.Stored)
+		}
+		out = append(out, fmt.Sprintf("%s/%d %s correct=%d computed=%t stored=%s", c.Season, c.Week, c.UserID, c.Correct, c.Computed, stored))
+	}
+	return out
+}